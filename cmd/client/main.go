@@ -8,40 +8,209 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/google/uuid"
 	pb "github.com/teleport-jobworker/pkg/proto"
 	"github.com/teleport-jobworker/pkg/rpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // TODO in production I would use https://github.com/spf13/cobra to have some advance CLI options
 // such as being able to specify flags for specific commands, such as worker logs -f instead of worker -f logs
 var (
-	port       = flag.Int("port", 50051, "the port to serve on")
-	cpuWeight  = flag.Int("cpu", 100, "CPU weight as defined y cgroups v2 `cpu.weight` interface file")
-	memLimit   = flag.String("mem", "100M", "Memory limit as defined y cgroups v2 `mem.high` interface file")
-	ioWeight   = flag.Int("io", 50, "IO weight as defined y cgroups v2 `io.weight` interface file")
-	followLogs = flag.Bool("f", false, "Follows the job's logs, similiar to tail -f")
+	port        = flag.Int("port", 50051, "the port to serve on")
+	cpuWeight   = flag.Int("cpu", 100, "CPU weight as defined y cgroups v2 `cpu.weight` interface file")
+	memLimit    = flag.String("mem", "100M", "Memory limit as defined y cgroups v2 `mem.high` interface file")
+	ioWeight    = flag.Int("io", 50, "IO weight as defined y cgroups v2 `io.weight` interface file")
+	cpuMax      = flag.Int("cpu-max", 0, "CPU limit in millicores (1000 = 1 CPU) as defined by cgroups v2 `cpu.max` interface file")
+	cpuset      = flag.String("cpuset", "", "CPUs to pin the job to as defined by cgroups v2 `cpuset.cpus` interface file, e.g. 0-3")
+	timeout     = flag.Duration("timeout", 0, "Maximum runtime of the job before it's stopped, e.g. 2h. 0 for no limit")
+	restart     = flag.String("restart", "never", "Restart the job's command when it exits: never, on-failure or always")
+	maxRetries  = flag.Int("max-retries", 0, "Maximum number of times the job is restarted, 0 for no limit")
+	priority    = flag.Int("priority", 0, "Priority of the job when queued and the server orders it's queue by priority, higher runs first")
+	after       = flag.String("after", "", "Comma separated job UUIDs to wait for before starting the job, optionally with a condition success (default) or completion, e.g. {uuid},{uuid}=completion")
+	concurrency = flag.String("concurrency", "allow", "What a schedule does when a previous run is still running: allow, forbid or replace")
+	grace       = flag.Duration("grace", 0, "Time to wait after SIGTERM before a stopped job is killed, 0 for the server's default")
+	escalation  = flag.String("escalation", "", "Comma separated signals to stop a job with, each optionally followed by how long to wait for it to exit, e.g. INT=10s,TERM=30s,KILL")
+	name        = flag.String("name", "", "Name to refer to the job by instead of it's UUID, unique among your jobs that haven't completed")
+	labels      = flag.String("labels", "", "Comma separated labels to identify the job by, e.g. team=infra,pipeline=nightly")
+	annotations = flag.String("annotations", "", "Comma separated annotations with other information about the job, e.g. ticket=OPS-1")
+	count       = flag.Int("count", 0, "Start an array of this many copies of the command, each with JOB_ARRAY_INDEX and JOB_ARRAY_SIZE in it's environment")
+	maxParallel = flag.Int("max-parallel", 0, "Maximum number of an array's jobs that run at once, 0 for no limit")
+	requestID   = flag.String("request-id", "", "Token that makes retrying start safe, the same job is returned for a retry with the same token. Generated if not given")
+	waitTimeout = flag.Duration("wait-timeout", 0, "Maximum time to wait for a job to complete, 0 to wait indefinitely")
+	followLogs  = flag.Bool("f", false, "Follows the job's logs, similiar to tail -f")
 )
 
+// keyValues parses comma separated key=value pairs, i.e. the job's labels and annotations
+func keyValues(flagName, pairs string) (map[string]string, error) {
+	values := map[string]string{}
+	if pairs == "" {
+		return values, nil
+	}
+	for _, pair := range strings.Split(pairs, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %q, expected key=value", flagName, pair)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// startFailed prints why a job failed to start, and how to retry if the job may have started before the request failed
+func startFailed(err error) {
+	fmt.Printf("error starting job: %v\n", err)
+	if status.Code(err) == codes.DeadlineExceeded || status.Code(err) == codes.Unavailable {
+		fmt.Printf("The job may have started, retry without starting a duplicate: ./worker -request-id %s ... start ...\n", *requestID)
+	}
+}
+
+// jobRef returns how to refer to a job in the output, it's name and UUID if it has a name
+func jobRef(id, name string) string {
+	if name == "" {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", name, id)
+}
+
+// formatKeyValues formats labels or annotations as sorted key=value pairs
+func formatKeyValues(values map[string]string) string {
+	pairs := []string{}
+	for key, value := range values {
+		pairs = append(pairs, key+"="+value)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// listRequest builds a List request from the ls action's flags
+func listRequest(states, command, selector string, since time.Duration, limit int, page string) (*pb.ListRequest, error) {
+	req := &pb.ListRequest{Command: command, Selector: selector, PageSize: int32(limit), PageToken: page}
+	if states != "" {
+		for _, state := range strings.Split(states, ",") {
+			value, ok := pb.JobState_value["JOB_STATE_"+strings.ToUpper(state)]
+			if !ok {
+				return nil, fmt.Errorf("invalid state %q, expected pending, running, stopping, exited, stopped or failed", state)
+			}
+			req.States = append(req.States, pb.JobState(value))
+		}
+	}
+	if since > 0 {
+		req.CreatedAfter = timestamppb.New(time.Now().Add(-since))
+	}
+	return req, nil
+}
+
+// exitCode returns the exit code for the wait action, the job's own exit code or 1 if it did not exit successfully
+// without one, e.g. it was stopped, killed by a signal or failed to start
+func exitCode(status *pb.JobStatus) int {
+	if status.State != pb.JobState_JOB_STATE_EXITED && status.ExitCode <= 0 {
+		return 1
+	}
+	return int(status.ExitCode)
+}
+
 func help() {
 	fmt.Println("not enough arguments! usage:")
-	fmt.Println(`./client start bash -c "echo hello"`)
+	fmt.Println(`./client [-name hello] [-labels team=infra] [-annotations ticket=OPS-1] start bash -c "echo hello"`)
+	fmt.Println(`jobs can be referred to by {uuid}, their name or a unique prefix of their uuid`)
 	fmt.Println(`or ./client status {uuid}`)
+	fmt.Println(`or ./client ls [-state running,failed] [-command text] [-l team=infra] [-since 24h] [-limit 50] [-page {token}]`)
 	fmt.Println(`or ./client stop {uuid}`)
+	fmt.Println(`or ./client bulk-stop team=infra,env!=prod`)
+	fmt.Println(`or ./client [-wait-timeout 1h] wait {uuid}`)
+	fmt.Println(`or ./client delete {uuid}`)
+	fmt.Println(`or ./client kill -s HUP [-target group|process|cgroup] {uuid}`)
 	fmt.Println(`or ./client logs {uuid}`)
+	fmt.Println(`or ./client watch [-from {revision}] [-l team=infra] [uuid...]`)
+	fmt.Println(`or ./client leaks`)
+	fmt.Println(`or ./client schedule "*/15 * * * *" bash -c "echo hello"`)
+	fmt.Println(`or ./client schedules`)
+	fmt.Println(`or ./client pause {schedule uuid}`)
+	fmt.Println(`or ./client resume {schedule uuid}`)
+	fmt.Println(`or ./client unschedule {schedule uuid}`)
+	fmt.Println(`or ./client workflow {workflow.json}`)
+	fmt.Println(`or ./client workflow-status {workflow uuid}`)
+	fmt.Println(`or ./client -count 10 [-max-parallel 2] start ./shard.sh`)
+	fmt.Println(`or ./client array-status {array uuid}`)
+	fmt.Println(`or ./client [-f] array-logs {array uuid}`)
+	fmt.Println(`or ./client array-stop {array uuid}`)
+}
+
+// jobOpts returns the job options set by the CLI flags
+func jobOpts() *pb.JobOpts {
+	opts := &pb.JobOpts{
+		CpuWeight:     int32(*cpuWeight),
+		IoWeight:      int32(*ioWeight),
+		MemLimit:      *memLimit,
+		CpuMax:        int32(*cpuMax),
+		Cpuset:        *cpuset,
+		RestartPolicy: *restart,
+		MaxRetries:    int32(*maxRetries),
+		Priority:      int32(*priority),
+	}
+	if *timeout > 0 {
+		opts.Timeout = durationpb.New(*timeout)
+	}
+	return opts
+}
+
+// stopRequest returns a Stop request for the job with the grace period or escalation set by the CLI flags
+func stopRequest(id string) (*pb.StopRequest, error) {
+	req := &pb.StopRequest{Id: id}
+	if *grace > 0 {
+		req.GracePeriod = durationpb.New(*grace)
+	}
+	if *escalation == "" {
+		return req, nil
+	}
+	for _, step := range strings.Split(*escalation, ",") {
+		signal, wait, ok := strings.Cut(step, "=")
+		pbStep := &pb.StopStep{Signal: signal}
+		if ok {
+			d, err := time.ParseDuration(wait)
+			if err != nil {
+				return nil, fmt.Errorf("invalid escalation step %q, expected signal=duration", step)
+			}
+			pbStep.Wait = durationpb.New(d)
+		}
+		req.Escalation = append(req.Escalation, pbStep)
+	}
+	return req, nil
+}
+
+// dependencies returns the jobs set by the -after flag
+func dependencies() []*pb.Dependency {
+	deps := []*pb.Dependency{}
+	if *after == "" {
+		return deps
+	}
+	for _, dep := range strings.Split(*after, ",") {
+		id, condition, _ := strings.Cut(dep, "=")
+		deps = append(deps, &pb.Dependency{Id: id, Condition: condition})
+	}
+	return deps
 }
 
 func main() {
 	// Parse CLI args
-	if len(os.Args) < 3 {
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 1 || (args[0] != "leaks" && args[0] != "schedules" && args[0] != "watch" && args[0] != "ls" && len(args) < 2) || (args[0] == "schedule" && len(args) < 3) {
 		help()
 		return
 	}
-	flag.Parse()
-	args := flag.Args()
 
 	// Set up gRPC client
 	tlsConfig, err := loadTLSConfig("certs/client.pem", "certs/client-key.pem", "certs/root.pem")
@@ -63,34 +232,218 @@ func main() {
 	// Decide which action to execute
 	switch args[0] {
 	case "start":
-		id, err := rpc.Start(ctx, client, args[1], args[2:], int32(*cpuWeight), int32(*ioWeight), *memLimit)
+		jobLabels, err := keyValues("label", *labels)
 		if err != nil {
-			fmt.Printf("error starting job: %v\n", err)
+			fmt.Println(err)
+			break
+		}
+		jobAnnotations, err := keyValues("annotation", *annotations)
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		if *requestID == "" {
+			*requestID = uuid.NewString()
+		}
+		req := &pb.StartRequest{
+			RequestId:   *requestID,
+			Name:        *name,
+			Command:     args[1],
+			Args:        args[2:],
+			Opts:        jobOpts(),
+			After:       dependencies(),
+			Labels:      jobLabels,
+			Annotations: jobAnnotations,
+			Count:       int32(*count),
+			MaxParallel: int32(*maxParallel),
+		}
+		if *count > 0 {
+			arrayID, ids, err := rpc.StartArray(ctx, client, req)
+			if err != nil {
+				startFailed(err)
+				break
+			}
+			fmt.Printf("Started job array %s, it's jobs may be pending until a slot is free\n", arrayID)
+			for i, id := range ids {
+				fmt.Printf("\t%d\t%s\n", i, id)
+			}
+			fmt.Printf("View the logs: ./worker array-logs %s\n", arrayID)
+			fmt.Printf("Check the status: ./worker array-status %s\n", arrayID)
+			fmt.Printf("Stop the array: ./worker array-stop %s\n", arrayID)
+			break
+		}
+		id, err := rpc.StartWith(ctx, client, req)
+		if err != nil {
+			startFailed(err)
 		} else {
-			fmt.Printf("Started Job %s\n", id)
-			fmt.Printf("View the logs: ./worker logs %s\n", id)
-			fmt.Printf("Check the status: ./worker status %s\n", id)
-			fmt.Printf("Stop the job: ./worker stop %s\n", id)
+			fmt.Printf("Started Job %s, it may be queued until a slot is free\n", jobRef(id, *name))
+			ref := id
+			if *name != "" {
+				ref = *name
+			}
+			fmt.Printf("View the logs: ./worker logs %s\n", ref)
+			fmt.Printf("Check the status: ./worker status %s\n", ref)
+			fmt.Printf("Stop the job: ./worker stop %s\n", ref)
 		}
 		break
 	case "stop":
-		if err = rpc.Stop(ctx, client, args[1]); err != nil {
+		req, err := stopRequest(args[1])
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		if state, err := rpc.StopWith(ctx, client, req); err != nil {
 			fmt.Printf("error stopping job: %v\n", err)
+		} else if state == pb.JobState_JOB_STATE_STOPPING {
+			fmt.Printf("Stopping job %s\n", args[1])
+			fmt.Printf("Check the status: ./worker status %s\n", args[1])
 		} else {
 			fmt.Printf("Stopped job %s\n", args[1])
 		}
 		break
+	case "bulk-stop":
+		stop, err := stopRequest("")
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		req := &pb.BulkStopRequest{Selector: args[1], GracePeriod: stop.GracePeriod, Escalation: stop.Escalation}
+		if ids, err := rpc.BulkStop(ctx, client, req); err != nil {
+			fmt.Printf("error stopping jobs: %v\n", err)
+		} else {
+			fmt.Printf("Stopping %d jobs\n", len(ids))
+			for _, id := range ids {
+				fmt.Println(id)
+			}
+		}
+		break
+	case "delete":
+		if err = rpc.Delete(ctx, client, args[1]); err != nil {
+			fmt.Printf("error deleting job: %v\n", err)
+		} else {
+			fmt.Printf("Deleted job %s\n", args[1])
+		}
+		break
+	case "kill":
+		// Like kill(1) the signal is given after the action, defaulting to TERM
+		killFlags := flag.NewFlagSet("kill", flag.ContinueOnError)
+		signal := killFlags.String("s", "TERM", "Signal to send by name or number, e.g. HUP, USR1 or 10")
+		target := killFlags.String("target", "group", "Processes to signal: group (the job's process group), process (the main process) or cgroup")
+		if err = killFlags.Parse(args[1:]); err != nil || killFlags.NArg() < 1 {
+			help()
+			break
+		}
+		id := killFlags.Arg(0)
+		if err = rpc.Signal(ctx, client, id, *signal, *target); err != nil {
+			fmt.Printf("error signalling job: %v\n", err)
+		} else {
+			fmt.Printf("Sent %s to job %s\n", *signal, id)
+		}
+		break
 	case "status":
 		if status, err := rpc.Status(ctx, client, args[1]); err != nil {
 			fmt.Printf("error getting status for job: %v\n", err)
 		} else {
 			fmt.Println("Job Status")
 			fmt.Println("ID: ", status.Id)
+			if status.Name != "" {
+				fmt.Println("Name: ", status.Name)
+			}
+			fmt.Println("Owner: ", status.Owner)
+			fmt.Println("Command: ", strings.Join(append([]string{status.Command}, status.Args...), " "))
+			fmt.Println("State: ", strings.ToLower(strings.TrimPrefix(status.State.String(), "JOB_STATE_")))
+			if len(status.Labels) > 0 {
+				fmt.Println("Labels: ", formatKeyValues(status.Labels))
+			}
+			if len(status.Annotations) > 0 {
+				fmt.Println("Annotations: ", formatKeyValues(status.Annotations))
+			}
+			fmt.Println("Created: ", status.Created.AsTime().Local().Format(time.RFC3339))
+			if status.Started != nil {
+				fmt.Println("Started: ", status.Started.AsTime().Local().Format(time.RFC3339))
+				fmt.Println("Duration: ", status.Duration.AsDuration().Round(time.Second))
+			}
+			if status.Finished != nil {
+				fmt.Println("Finished: ", status.Finished.AsTime().Local().Format(time.RFC3339))
+			}
+			if status.Retained {
+				fmt.Printf("Retained:  logs kept until deleted with ./worker delete %s\n", status.Id)
+			}
 			fmt.Println("PID: ", status.Pid)
 			fmt.Println("Running: ", status.Running)
+			if status.Pending {
+				fmt.Println("Pending: ", status.Pending)
+			}
+			if status.Queued {
+				fmt.Println("Queue Position: ", status.QueuePosition)
+			}
 			fmt.Println("Exit Code: ", status.ExitCode)
+			if status.ExitReason != "" {
+				fmt.Println("Exit Reason: ", status.ExitReason)
+			}
+			if status.Restarts > 0 {
+				fmt.Println("Restarts: ", status.Restarts)
+				fmt.Println("Last Exit Code: ", status.LastExitCode)
+			}
+			controls := []string{}
+			for control := range status.Capabilities {
+				controls = append(controls, control)
+			}
+			slices.Sort(controls)
+			for _, control := range controls {
+				fmt.Printf("%s: %s\n", control, status.Capabilities[control])
+			}
 		}
 		break
+	case "ls":
+		lsFlags := flag.NewFlagSet("ls", flag.ContinueOnError)
+		states := lsFlags.String("state", "", "Comma separated states to list, e.g. running,failed")
+		command := lsFlags.String("command", "", "Only list jobs whose command contains the text")
+		selector := lsFlags.String("l", "", "Only list jobs with matching labels, e.g. team=infra,env!=prod")
+		since := lsFlags.Duration("since", 0, "Only list jobs created within the duration, e.g. 24h")
+		limit := lsFlags.Int("limit", 0, "Maximum number of jobs to list, 0 for the server's default")
+		page := lsFlags.String("page", "", "Token of the page to list, as printed after the previous page")
+		if err = lsFlags.Parse(args[1:]); err != nil {
+			help()
+			break
+		}
+		req, err := listRequest(*states, *command, *selector, *since, *limit, *page)
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		jobs, next, err := rpc.List(ctx, client, req)
+		if err != nil {
+			fmt.Printf("error listing jobs: %v\n", err)
+			break
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSTATE\tCREATED\tDURATION\tEXIT CODE\tLABELS\tCOMMAND")
+		for _, job := range jobs {
+			state := strings.ToLower(strings.TrimPrefix(job.State.String(), "JOB_STATE_"))
+			command := strings.Join(append([]string{job.Command}, job.Args...), " ")
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", job.Id, job.Name, state, job.Created.AsTime().Local().Format(time.RFC3339), job.Duration.AsDuration().Round(time.Second), job.ExitCode, formatKeyValues(job.Labels), command)
+		}
+		w.Flush()
+		if next != "" {
+			fmt.Printf("Next page: ./worker ls -page %s\n", next)
+		}
+		break
+	case "wait":
+		waitCtx, waitCancel := context.Background(), context.CancelFunc(func() {})
+		if *waitTimeout > 0 {
+			waitCtx, waitCancel = context.WithTimeout(waitCtx, *waitTimeout)
+		}
+		status, err := rpc.Wait(waitCtx, client, args[1])
+		waitCancel()
+		if err != nil {
+			fmt.Printf("error waiting for job: %v\n", err)
+			conn.Close()
+			os.Exit(1)
+		}
+		fmt.Printf("Job %s %s (%d)\n", status.Id, strings.ToLower(strings.TrimPrefix(status.State.String(), "JOB_STATE_")), status.ExitCode)
+		conn.Close()
+		os.Exit(exitCode(status))
 	case "logs":
 		streamCtx, streamCancel := context.WithTimeout(context.Background(), 10*time.Minute)
 		defer streamCancel()
@@ -98,8 +451,167 @@ func main() {
 			fmt.Printf("error getting job logs: %v\n", err)
 		}
 		break
+	case "watch":
+		// Events are streamed until interrupted, so a watch isn't bound by the usual request timeout
+		watchFlags := flag.NewFlagSet("watch", flag.ContinueOnError)
+		revision := watchFlags.Uint64("from", 0, "Resume from the revision of the last event received, 0 for only new events")
+		selector := watchFlags.String("l", "", "Only watch jobs with matching labels, e.g. team=infra,env!=prod")
+		if err = watchFlags.Parse(args[1:]); err != nil {
+			help()
+			break
+		}
+		var last uint64
+		req := &pb.WatchRequest{Revision: *revision, Ids: watchFlags.Args(), Selector: *selector}
+		err = rpc.Watch(context.Background(), client, req, func(event *pb.Event) {
+			last = event.Revision
+			state := strings.ToLower(strings.TrimPrefix(event.JobStatus.State.String(), "JOB_STATE_"))
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\n", event.Revision, event.Time.AsTime().Local().Format(time.RFC3339), jobRef(event.JobId, event.JobStatus.GetName()), event.Type, state)
+		})
+		if err != nil && err != io.EOF {
+			fmt.Printf("error watching jobs: %v\n", err)
+			if last > 0 {
+				fmt.Printf("Resume watching: ./worker watch -from %d %s\n", last, strings.Join(watchFlags.Args(), " "))
+			}
+		}
+		break
+	case "leaks":
+		if leaks, err := rpc.Leaks(ctx, client); err != nil {
+			fmt.Printf("error getting leaked resources: %v\n", err)
+		} else {
+			fmt.Println("Leaked Resources")
+			for _, leak := range leaks {
+				fmt.Printf("%s\t%s\t%s\t%s\t%s\n", leak.Time.AsTime().Format(time.RFC3339), leak.Kind, leak.JobId, leak.Resource, leak.Error)
+			}
+		}
+		break
+	case "schedule":
+		if id, err := rpc.Schedule(ctx, client, args[1], *concurrency, args[2], args[3:], jobOpts()); err != nil {
+			fmt.Printf("error scheduling job: %v\n", err)
+		} else {
+			fmt.Printf("Scheduled %s\n", id)
+			fmt.Printf("List the schedule's runs: ./worker schedules\n")
+		}
+		break
+	case "schedules":
+		if schedules, err := rpc.ListSchedules(ctx, client); err != nil {
+			fmt.Printf("error listing schedules: %v\n", err)
+		} else {
+			fmt.Println("Schedules")
+			for _, s := range schedules {
+				next := "paused"
+				if s.Next != nil {
+					next = s.Next.AsTime().Local().Format(time.RFC3339)
+				}
+				fmt.Printf("%s\t%q\t%s\t%s\t%s %v\n", s.Id, s.Spec, s.ConcurrencyPolicy, next, s.Command, s.Args)
+				for _, run := range s.Runs {
+					fmt.Printf("\t%s\n", run)
+				}
+			}
+		}
+		break
+	case "pause":
+		if err = rpc.PauseSchedule(ctx, client, args[1]); err != nil {
+			fmt.Printf("error pausing schedule: %v\n", err)
+		} else {
+			fmt.Printf("Paused schedule %s\n", args[1])
+		}
+		break
+	case "resume":
+		if err = rpc.ResumeSchedule(ctx, client, args[1]); err != nil {
+			fmt.Printf("error resuming schedule: %v\n", err)
+		} else {
+			fmt.Printf("Resumed schedule %s\n", args[1])
+		}
+		break
+	case "unschedule":
+		if err = rpc.DeleteSchedule(ctx, client, args[1]); err != nil {
+			fmt.Printf("error deleting schedule: %v\n", err)
+		} else {
+			fmt.Printf("Deleted schedule %s\n", args[1])
+		}
+		break
+	case "workflow":
+		// The workflow file is the JSON encoding of a SubmitWorkflowRequest, e.g. {"nodes": [{"name": "extract", ...}]}
+		b, err := os.ReadFile(args[1])
+		if err != nil {
+			fmt.Printf("error reading workflow: %v\n", err)
+			break
+		}
+		req := &pb.SubmitWorkflowRequest{}
+		if err = protojson.Unmarshal(b, req); err != nil {
+			fmt.Printf("error parsing workflow: %v\n", err)
+			break
+		}
+		if id, jobs, err := rpc.SubmitWorkflow(ctx, client, req.Nodes); err != nil {
+			fmt.Printf("error submitting workflow: %v\n", err)
+		} else {
+			fmt.Printf("Submitted workflow %s\n", id)
+			for _, node := range req.Nodes {
+				fmt.Printf("\t%s\t%s\n", node.Name, jobs[node.Name])
+			}
+			fmt.Printf("Check the status: ./worker workflow-status %s\n", id)
+		}
+		break
+	case "workflow-status":
+		if status, err := rpc.WorkflowStatus(ctx, client, args[1]); err != nil {
+			fmt.Printf("error getting status for workflow: %v\n", err)
+		} else {
+			fmt.Println("Workflow Status")
+			fmt.Println("ID: ", status.Id)
+			fmt.Println("State: ", status.State)
+			for _, node := range status.Nodes {
+				state := "running"
+				if node.JobStatus.Pending {
+					state = "pending"
+				} else if node.JobStatus.Queued {
+					state = "queued"
+				} else if node.JobStatus.ExitReason != "" {
+					state = fmt.Sprintf("%s (%d)", node.JobStatus.ExitReason, node.JobStatus.ExitCode)
+				}
+				fmt.Printf("\t%s\t%s\t%s\n", node.Name, node.JobStatus.Id, state)
+			}
+		}
+		break
+	case "array-status":
+		if status, err := rpc.ArrayStatus(ctx, client, args[1]); err != nil {
+			fmt.Printf("error getting status for job array: %v\n", err)
+		} else {
+			fmt.Println("Array Status")
+			fmt.Println("ID: ", status.Id)
+			fmt.Println("State: ", status.State)
+			fmt.Println("Max Parallel: ", status.MaxParallel)
+			for i, job := range status.Jobs {
+				state := strings.ToLower(strings.TrimPrefix(job.State.String(), "JOB_STATE_"))
+				if job.ExitReason != "" {
+					state = fmt.Sprintf("%s (%d)", job.ExitReason, job.ExitCode)
+				}
+				fmt.Printf("\t%d\t%s\t%s\n", i, job.Id, state)
+			}
+		}
+		break
+	case "array-logs":
+		streamCtx, streamCancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer streamCancel()
+		if err = rpc.ArrayLogs(streamCtx, client, args[1], *followLogs); err != nil && err != io.EOF {
+			fmt.Printf("error getting job array logs: %v\n", err)
+		}
+		break
+	case "array-stop":
+		stop, err := stopRequest("")
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		req := &pb.StopArrayRequest{Id: args[1], GracePeriod: stop.GracePeriod, Escalation: stop.Escalation}
+		if ids, err := rpc.StopArray(ctx, client, req); err != nil {
+			fmt.Printf("error stopping job array: %v\n", err)
+		} else {
+			fmt.Printf("Stopping %d jobs in array %s\n", len(ids), args[1])
+			fmt.Printf("Check the status: ./worker array-status %s\n", args[1])
+		}
+		break
 	default:
-		fmt.Printf("%s action not supported, try start, status, stop or logs", os.Args[1])
+		fmt.Printf("%s action not supported, try start, ls, status, wait, stop, bulk-stop, delete, kill, logs, watch, leaks, schedule, schedules, pause, resume, unschedule, workflow, workflow-status, array-status, array-logs or array-stop", args[0])
 		help()
 		break
 	}
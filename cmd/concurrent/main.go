@@ -23,7 +23,7 @@ func main() {
 		return
 	}
 	// Define job's command and options
-	opts := jobworker.JobOpts{100, 50, 100 * jobworker.CgroupMB}
+	opts := jobworker.JobOpts{CPUWeight: 100, IOWeight: 50, MemLimit: 100 * jobworker.CgroupMB}
 	// Run the job
 	job, err := jobworker.Start(opts, os.Args[2], os.Args[3:]...)
 	if err != nil {
@@ -5,15 +5,59 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/teleport-jobworker/pkg/jobworker"
 	"github.com/teleport-jobworker/pkg/rpc"
 )
 
-var port = flag.Int("port", 50051, "the port to serve on")
+var (
+	port       = flag.Int("port", 50051, "the port to serve on")
+	admins     = flag.String("admins", "", "comma separated common names of client certs allowed to call admin RPCs")
+	maxTimeout = flag.Duration("max-timeout", 0, "maximum runtime of a job, jobs without a timeout are given the maximum. 0 for no limit")
+	maxRunning = flag.Int("max-running", 0, "maximum number of jobs running at once before jobs are queued, 0 for no limit")
+	maxOwner   = flag.Int("max-running-per-owner", 0, "maximum number of an owner's jobs running at once before they're queued, 0 for no limit")
+	queueOrder = flag.String("queue-order", "fifo", "order queued jobs are started in: fifo or priority")
+	ownerMax   = flag.String("owner-max-timeout", "", "comma separated maximum runtimes overriding -max-timeout for owners, e.g. alice=2h,bob=24h")
+	retainJobs = flag.Int("retain-jobs", 0, "number of each owner's most recently completed jobs kept until they're deleted, 0 for no limit")
+	retainFor  = flag.Duration("retain-for", 0, "how long completed jobs are kept until they're deleted, e.g. 24h. 0 for no limit")
+	signals    = flag.String("allowed-signals", "", "comma separated signals owners may send to their jobs, e.g. HUP,USR1. Defaults to HUP,INT,QUIT,TERM,KILL,USR1,USR2,WINCH")
+)
 
 func main() {
 	// Parse CLI args
 	flag.Parse()
+	if *admins != "" {
+		jobworker.ADMIN_OWNERS = strings.Split(*admins, ",")
+	}
+	jobworker.MAX_JOB_TIMEOUT = *maxTimeout
+	jobworker.MAX_RUNNING_JOBS = *maxRunning
+	jobworker.MAX_RUNNING_JOBS_PER_OWNER = *maxOwner
+	jobworker.QUEUE_ORDER = *queueOrder
+	jobworker.RETAIN_JOBS_PER_OWNER = *retainJobs
+	jobworker.RETAIN_JOBS_FOR = *retainFor
+	if *ownerMax != "" {
+		for _, limit := range strings.Split(*ownerMax, ",") {
+			owner, val, ok := strings.Cut(limit, "=")
+			timeout, err := time.ParseDuration(val)
+			if !ok || err != nil {
+				log.Fatalf("invalid owner max timeout %q, expected owner=duration", limit)
+			}
+			jobworker.OWNER_MAX_JOB_TIMEOUT[owner] = timeout
+		}
+	}
+	if *signals != "" {
+		jobworker.ALLOWED_SIGNALS = []syscall.Signal{}
+		for _, name := range strings.Split(*signals, ",") {
+			sig, err := jobworker.ParseSignal(name)
+			if err != nil {
+				log.Fatalf("invalid allowed signal: %v", err)
+			}
+			jobworker.ALLOWED_SIGNALS = append(jobworker.ALLOWED_SIGNALS, sig)
+		}
+	}
 	log.Printf("server starting on port %d...\n", *port)
 	// Setup and run gRPC server
 	s := rpc.NewServer()
@@ -0,0 +1,268 @@
+package jobworker
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// HostResources are the totals of the host's resources that jobs can reserve
+type HostResources struct {
+	MemTotal    CgroupByte
+	CPUs        int
+	AllowedCPUs []int // IDs of the CPUs jobs can be pinned to, which aren't necessarily 0 to CPUs-1
+}
+
+// HostTotals returns the host's total memory and the CPUs in the worker's affinity mask, which jobs inherit
+func HostTotals() (HostResources, error) {
+	info := &syscall.Sysinfo_t{}
+	if err := syscall.Sysinfo(info); err != nil {
+		return HostResources{}, fmt.Errorf("failed to get host memory: %w", err)
+	}
+	cpus, err := allowedCPUs()
+	if err != nil {
+		return HostResources{}, err
+	}
+	return HostResources{
+		MemTotal:    CgroupByte(info.Totalram * uint64(info.Unit)),
+		CPUs:        len(cpus),
+		AllowedCPUs: cpus,
+	}, nil
+}
+
+// allowedCPUs returns the IDs of the CPUs in the worker's affinity mask in ascending order
+func allowedCPUs() ([]int, error) {
+	set := unix.CPUSet{}
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		return nil, fmt.Errorf("failed to get host CPUs: %w", err)
+	}
+	cpus := []int{}
+	for c := 0; len(cpus) < set.Count(); c++ {
+		if set.IsSet(c) {
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus, nil
+}
+
+// Shortage describes a resource that could not satisfy a job's request
+type Shortage struct {
+	Resource  string // "memory", "cpu" or "cpuset"
+	Requested int64
+	Available int64
+}
+
+func (s Shortage) String() string {
+	return fmt.Sprintf("%s requested %d but only %d available", s.Resource, s.Requested, s.Available)
+}
+
+// ErrResourcesExhausted is returned when starting a job would oversubscribe the host
+type ErrResourcesExhausted struct {
+	Shortages []Shortage
+}
+
+func (e *ErrResourcesExhausted) Error() string {
+	msgs := []string{}
+	for _, s := range e.Shortages {
+		msgs = append(msgs, s.String())
+	}
+	return fmt.Sprintf("insufficient resources: %s", strings.Join(msgs, ", "))
+}
+
+// reservation is the resources held by a job, job is nil while the job is being started
+type reservation struct {
+	opts JobOpts
+	cpus []int
+	job  *Job
+}
+
+// Admission tracks the resources reserved by jobs and rejects starting jobs that would exceed the host's totals
+// multiplied by the overcommit ratio. Memory is reserved using JobOpts.MemLimit, CPU using JobOpts.CPUMax and
+// pinned CPUs using JobOpts.CPUSet. Each pinned CPU has a capacity of 1000 millicores multiplied by the overcommit
+// ratio, and a pinned job reserves its CPU max, or all of its CPUs if it has none, spread evenly over its CPUs. Weights
+// are relative so are not reserved. A job's reservation is released once it is no longer running.
+type Admission struct {
+	sync.Mutex
+	host         HostResources
+	ratio        float64
+	reservations []*reservation
+}
+
+// NewAdmission initialises an Admission for the host's resources and overcommit ratio
+func NewAdmission(host HostResources, ratio float64) *Admission {
+	return &Admission{host: host, ratio: ratio, reservations: []*reservation{}}
+}
+
+// Start calls StartWithController using the default ResourceController Cgroup
+func (a *Admission) Start(opts JobOpts, cmd string, args ...string) (*Job, error) {
+	return a.StartWithController(&Cgroup{"/sys/fs/cgroup"}, opts, cmd, args...)
+}
+
+// StartWithController creates a job using NewJobWithController and starts it with StartJob
+func (a *Admission) StartWithController(con ResourceController, opts JobOpts, cmd string, args ...string) (*Job, error) {
+	job := NewJobWithController(con, opts, cmd, args...)
+	if err := a.StartJob(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// StartJob reserves the resources in a pending job's opts and starts the job, returning ErrResourcesExhausted with the
+// resources that are short if the job would oversubscribe the host
+func (a *Admission) StartJob(job *Job) error {
+	r, err := a.reserve(job.opts)
+	if err != nil {
+		return err
+	}
+	err = job.Start()
+	a.Lock()
+	defer a.Unlock()
+	if err != nil {
+		a.release(r)
+		return err
+	}
+	r.job = job
+	return nil
+}
+
+// reserve checks the job's requested resources are available and if so holds them until the job finishes
+func (a *Admission) reserve(opts JobOpts) (*reservation, error) {
+	cpus, err := parseCPUSet(opts.CPUSet, a.host.AllowedCPUs)
+	if err != nil {
+		return nil, err
+	}
+	a.Lock()
+	defer a.Unlock()
+	a.prune()
+	shortages := []Shortage{}
+	// Memory and CPU are compared against the host total, multiplied by the overcommit ratio
+	var mem CgroupByte
+	var cpu int64
+	pinned := map[int]int64{}
+	for _, r := range a.reservations {
+		mem += r.opts.MemLimit
+		cpu += int64(r.opts.CPUMax)
+		share := pinnedShare(r.opts, r.cpus)
+		for _, c := range r.cpus {
+			pinned[c] += share
+		}
+	}
+	if opts.MemLimit > 0 {
+		available := int64(float64(a.host.MemTotal)*a.ratio) - int64(mem)
+		if int64(opts.MemLimit) > available {
+			shortages = append(shortages, Shortage{"memory", int64(opts.MemLimit), max(available, 0)})
+		}
+	}
+	if opts.CPUMax > 0 {
+		available := int64(float64(a.host.CPUs*1000)*a.ratio) - cpu
+		if int64(opts.CPUMax) > available {
+			shortages = append(shortages, Shortage{"cpu", int64(opts.CPUMax), max(available, 0)})
+		}
+	}
+	// Each pinned CPU is compared against a single CPU, multiplied by the overcommit ratio
+	share := pinnedShare(opts, cpus)
+	for _, c := range cpus {
+		available := int64(1000*a.ratio) - pinned[c]
+		if share > available {
+			shortages = append(shortages, Shortage{fmt.Sprintf("cpuset %d", c), share, max(available, 0)})
+		}
+	}
+	if len(shortages) > 0 {
+		return nil, &ErrResourcesExhausted{shortages}
+	}
+	r := &reservation{opts: opts, cpus: cpus}
+	a.reservations = append(a.reservations, r)
+	return r, nil
+}
+
+// pinnedShare returns the millicores a job reserves on each of its pinned CPUs, its CPU max spread evenly over the
+// CPUs or all of each CPU if it has no CPU max
+func pinnedShare(opts JobOpts, cpus []int) int64 {
+	if len(cpus) == 0 {
+		return 0
+	}
+	share := int64(1000)
+	if opts.CPUMax > 0 {
+		share = min(int64(opts.CPUMax)/int64(len(cpus)), share)
+	}
+	return share
+}
+
+// release removes a reservation, the caller must hold the lock
+func (a *Admission) release(r *reservation) {
+	for i, other := range a.reservations {
+		if other == r {
+			a.reservations = append(a.reservations[:i], a.reservations[i+1:]...)
+			return
+		}
+	}
+}
+
+// prune releases the reservations of jobs that are no longer running, the caller must hold the lock
+func (a *Admission) prune() {
+	running := []*reservation{}
+	for _, r := range a.reservations {
+		if r.job == nil || r.job.isRunning() {
+			running = append(running, r)
+		}
+	}
+	a.reservations = running
+}
+
+// ValidateCPUSet checks a cpuset.cpus list such as "0-3,6" can be parsed and only has CPUs in the worker's affinity
+// mask, see HostTotals
+func ValidateCPUSet(set string) error {
+	if set == "" {
+		return nil
+	}
+	allowed, err := allowedCPUs()
+	if err != nil {
+		return err
+	}
+	_, err = parseCPUSet(set, allowed)
+	return err
+}
+
+// parseCPUSet parses a cpuset.cpus list such as "0-3,6" into the CPUs it contains, each range is checked against the
+// highest allowed CPU before it's expanded and each CPU must be one of the allowed CPUs, which are in ascending order
+func parseCPUSet(set string, allowed []int) ([]int, error) {
+	cpus := []int{}
+	if set == "" {
+		return cpus, nil
+	}
+	highest := -1
+	if len(allowed) > 0 {
+		highest = allowed[len(allowed)-1]
+	}
+	for _, part := range strings.Split(set, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("cpuset %q not valid: %w", set, err)
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("cpuset %q not valid: %w", set, err)
+			}
+		}
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("cpuset %q not valid", set)
+		}
+		if end > highest {
+			return nil, fmt.Errorf("cpuset %q not valid, CPU %d isn't available to jobs", set, end)
+		}
+		for c := start; c <= end; c++ {
+			if _, found := slices.BinarySearch(allowed, c); !found {
+				return nil, fmt.Errorf("cpuset %q not valid, CPU %d isn't available to jobs", set, c)
+			}
+			cpus = append(cpus, c)
+		}
+	}
+	return cpus, nil
+}
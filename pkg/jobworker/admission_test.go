@@ -0,0 +1,136 @@
+package jobworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdmission_Rejects_Oversubscribed_Memory(t *testing.T) {
+	mockUserId()
+	admission := NewAdmission(HostResources{MemTotal: 100 * CgroupMB, CPUs: 2, AllowedCPUs: []int{0, 1}}, 1)
+	args := []string{"-c", "while true; do sleep 2; done"}
+	// Reserve most of the host's memory
+	job, err := admission.StartWithController(&mockController{}, JobOpts{MemLimit: 80 * CgroupMB}, cmd, args...)
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	// Assert a job requesting more than what's left is rejected with the shortage
+	_, err = admission.StartWithController(&mockController{}, JobOpts{MemLimit: 50 * CgroupMB}, cmd, args...)
+	var exhausted *ErrResourcesExhausted
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("expected resources exhausted error but got: %v", err)
+	}
+	if len(exhausted.Shortages) != 1 || exhausted.Shortages[0].Resource != "memory" || exhausted.Shortages[0].Available != int64(20*CgroupMB) {
+		t.Errorf("expected memory shortage with 20M available, actual %v", exhausted.Shortages)
+	}
+	// Once the first job is stopped it's reservation is released
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = job.Stop(ctx); err != nil {
+		t.Fatalf("expected to be able to stop the job, error : %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	job, err = admission.StartWithController(&mockController{}, JobOpts{MemLimit: 50 * CgroupMB}, cmd, args...)
+	if err != nil {
+		t.Fatalf("expected job to be admitted after reservation was released: %v", err)
+	}
+	job.Stop(ctx)
+}
+
+func TestAdmission_Rejects_Oversubscribed_CPU(t *testing.T) {
+	mockUserId()
+	// Overcommit ratio of 2 allows 4 CPUs to be reserved and each CPU to be pinned twice
+	admission := NewAdmission(HostResources{MemTotal: CgroupGB, CPUs: 2, AllowedCPUs: []int{0, 1}}, 2)
+	if _, err := admission.reserve(JobOpts{CPUMax: 3000, CPUSet: "0-1"}); err != nil {
+		t.Fatalf("expected cpu to be reserved: %v", err)
+	}
+	if _, err := admission.reserve(JobOpts{CPUSet: "1"}); err != nil {
+		t.Fatalf("expected cpuset to be reserved: %v", err)
+	}
+	_, err := admission.reserve(JobOpts{CPUMax: 1500, CPUSet: "0-1"})
+	var exhausted *ErrResourcesExhausted
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("expected resources exhausted error but got: %v", err)
+	}
+	expected := []string{"cpu", "cpuset 1"}
+	if len(exhausted.Shortages) != len(expected) {
+		t.Fatalf("expected shortages %v, actual %v", expected, exhausted.Shortages)
+	}
+	for i, s := range exhausted.Shortages {
+		if s.Resource != expected[i] {
+			t.Errorf("expected shortage of %s, actual %s", expected[i], s.Resource)
+		}
+	}
+}
+
+func TestAdmission_Fractional_Ratio_Pinned_CPUs(t *testing.T) {
+	mockUserId()
+	// Overcommit ratio of 1.5 allows 1500 millicores to be pinned to each CPU
+	admission := NewAdmission(HostResources{MemTotal: CgroupGB, CPUs: 2, AllowedCPUs: []int{0, 1}}, 1.5)
+	if _, err := admission.reserve(JobOpts{CPUSet: "0"}); err != nil {
+		t.Fatalf("expected cpuset to be reserved: %v", err)
+	}
+	if _, err := admission.reserve(JobOpts{CPUMax: 1000, CPUSet: "0-1"}); err != nil {
+		t.Fatalf("expected half of each CPU to be reserved: %v", err)
+	}
+	_, err := admission.reserve(JobOpts{CPUSet: "0"})
+	var exhausted *ErrResourcesExhausted
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("expected resources exhausted error but got: %v", err)
+	}
+	if len(exhausted.Shortages) != 1 || exhausted.Shortages[0].Resource != "cpuset 0" || exhausted.Shortages[0].Available != 0 {
+		t.Errorf("expected cpuset 0 shortage with nothing available, actual %v", exhausted.Shortages)
+	}
+	if _, err = admission.reserve(JobOpts{CPUSet: "1"}); err != nil {
+		t.Errorf("expected the rest of CPU 1 to be reserved: %v", err)
+	}
+	// Overcommit ratio of 0.5 only allows half of each CPU to be pinned
+	admission = NewAdmission(HostResources{MemTotal: CgroupGB, CPUs: 2, AllowedCPUs: []int{0, 1}}, 0.5)
+	if _, err = admission.reserve(JobOpts{CPUSet: "0"}); !errors.As(err, &exhausted) {
+		t.Fatalf("expected a whole pinned CPU to be rejected but got: %v", err)
+	}
+	if exhausted.Shortages[0].Requested != 1000 || exhausted.Shortages[0].Available != 500 {
+		t.Errorf("expected 1000 requested with 500 available, actual %v", exhausted.Shortages)
+	}
+	if _, err = admission.reserve(JobOpts{CPUMax: 500, CPUSet: "0"}); err != nil {
+		t.Errorf("expected half of the CPU to be reserved: %v", err)
+	}
+}
+
+func TestParseCPUSet(t *testing.T) {
+	allowed := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	cpus, err := parseCPUSet("0-2,5", allowed)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	expected := []int{0, 1, 2, 5}
+	if len(cpus) != len(expected) {
+		t.Fatalf("expected cpus %v, actual %v", expected, cpus)
+	}
+	for i := range expected {
+		if cpus[i] != expected[i] {
+			t.Errorf("expected cpus %v, actual %v", expected, cpus)
+		}
+	}
+	if _, err = parseCPUSet("3-1", allowed); err == nil {
+		t.Error("expected invalid cpuset range to return an error")
+	}
+	// Assert CPUs the host doesn't have are rejected before the range is expanded
+	for _, set := range []string{"8", "0-2000000000"} {
+		if _, err = parseCPUSet(set, allowed); err == nil {
+			t.Errorf("expected cpuset %q to be rejected on a host with 8 CPUs", set)
+		}
+	}
+	// Assert only the CPUs in the worker's affinity mask are accepted
+	allowed = []int{4, 5, 6, 7}
+	if _, err = parseCPUSet("4-7", allowed); err != nil {
+		t.Errorf("expected cpuset of the allowed CPUs to be accepted: %v", err)
+	}
+	for _, set := range []string{"0-3", "3-4"} {
+		if _, err = parseCPUSet(set, allowed); err == nil {
+			t.Errorf("expected cpuset %q to be rejected when only CPUs 4-7 are allowed", set)
+		}
+	}
+}
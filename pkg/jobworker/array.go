@@ -0,0 +1,158 @@
+package jobworker
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Array is a job array, N copies of a command for parameter sweeps and sharded runs. Each job has it's index and the
+// size of the array in it's environment as JOB_ARRAY_INDEX and JOB_ARRAY_SIZE
+type Array struct {
+	ID       string
+	parallel int
+	jobs     []*Job
+}
+
+// ArrayStatus is a snapshot of an Array and all of it's jobs in index order, summarised like a Workflow
+type ArrayStatus struct {
+	ID       string
+	State    WorkflowState
+	Parallel int
+	Jobs     []JobStatus
+}
+
+// NewArray creates size pending jobs for the command owned by owner using the ResourceController, each with the
+// metadata, and submits them to be started with submit. If parallel is greater than 0, at most parallel jobs are
+// submitted at once and the rest are pending until earlier jobs complete, in index order. Stopping a pending job
+// cancels it.
+func NewArray(con ResourceController, owner string, size, parallel int, metadata Metadata, opts JobOpts, submit func(*Job) error, cmd string, args ...string) (*Array, error) {
+	if size < 1 || size > MAX_ARRAY_SIZE {
+		return nil, fmt.Errorf("array size must be between 1 and %d", MAX_ARRAY_SIZE)
+	}
+	if parallel < 0 || parallel > size {
+		return nil, fmt.Errorf("array parallelism must be between 0 and the array's size")
+	}
+	if parallel == 0 {
+		parallel = size
+	}
+	a := &Array{ID: uuid.New().String(), parallel: parallel, jobs: []*Job{}}
+	for i := 0; i < size; i++ {
+		job := NewJobWithController(con, opts, cmd, args...)
+		job.SetOwner(owner)
+		job.SetMetadata(metadata)
+		job.SetEnv([]string{"JOB_ARRAY_INDEX=" + strconv.Itoa(i), "JOB_ARRAY_SIZE=" + strconv.Itoa(size)})
+		job.waiting = true
+		a.jobs = append(a.jobs, job)
+	}
+	for _, job := range a.jobs {
+		job.publishCreated()
+	}
+	go a.run(submit)
+	return a, nil
+}
+
+// run submits the array's jobs in index order, waiting for a slot when parallel jobs haven't completed. Jobs that are
+// cancelled while waiting don't take a slot
+func (a *Array) run(submit func(*Job) error) {
+	slots := make(chan struct{}, a.parallel)
+	for _, job := range a.jobs {
+		select {
+		case slots <- struct{}{}:
+		case <-job.done:
+			continue
+		}
+		if !job.stopWaiting() {
+			<-slots
+			continue
+		}
+		go func(job *Job) {
+			<-job.done
+			<-slots
+		}(job)
+		if err := submit(job); err != nil {
+			fmt.Printf("failed to start job %s in array %s: %v\n", job.ID, a.ID, err)
+			job.completePending(StartFailed)
+		}
+	}
+}
+
+// Jobs returns the array's jobs in index order
+func (a *Array) Jobs() []*Job {
+	return append([]*Job{}, a.jobs...)
+}
+
+// Status returns the status of every job and summarises them as the array's state
+func (a *Array) Status() ArrayStatus {
+	status := ArrayStatus{ID: a.ID, State: WorkflowSucceeded, Parallel: a.parallel, Jobs: []JobStatus{}}
+	for _, job := range a.jobs {
+		if !job.isDone() {
+			status.State = WorkflowRunning
+		} else if status.State == WorkflowSucceeded && !job.succeeded() {
+			status.State = WorkflowFailed
+		}
+		status.Jobs = append(status.Jobs, job.Status())
+	}
+	return status
+}
+
+// Output returns a reader of the logs of the array's jobs, each line prefixed with the job's index, e.g. "[2] ".
+// With DontFollowLogs the logs of the jobs that have started are read in index order. With FollowLogs lines are read
+// as they're written, waiting for pending jobs to start, until every job has completed or the reader is closed
+func (a *Array) Output(mode OutputMode) io.ReadCloser {
+	pr, pw := io.Pipe()
+	r := &arrayReader{PipeReader: pr, w: pw, closed: make(chan struct{})}
+	if mode == DontFollowLogs {
+		go func() {
+			for i, job := range a.jobs {
+				r.copyLogs(i, job, mode)
+			}
+			pw.Close()
+		}()
+		return r
+	}
+	var wg sync.WaitGroup
+	for i, job := range a.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.copyLogs(i, job, mode)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		pw.Close()
+	}()
+	return r
+}
+
+// arrayReader reads the prefixed lines written by each of an array's jobs, closing it stops reading the jobs' logs
+type arrayReader struct {
+	*io.PipeReader
+	w         *io.PipeWriter
+	writing   sync.Mutex // serialises lines written by each job
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Close closes the reader, closing the pipe first unblocks any line being written
+func (r *arrayReader) Close() error {
+	err := r.PipeReader.Close()
+	r.closeOnce.Do(func() {
+		close(r.closed)
+	})
+	return err
+}
+
+// copyLogs writes each line of a job's logs prefixed with the job's index, lines of different jobs aren't interleaved
+func (r *arrayReader) copyLogs(index int, job *Job, mode OutputMode) {
+	job.readLines(mode, r.closed, func(line []byte) error {
+		r.writing.Lock()
+		defer r.writing.Unlock()
+		_, err := fmt.Fprintf(r.w, "[%d] %s\n", index, line)
+		return err
+	})
+}
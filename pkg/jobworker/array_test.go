@@ -0,0 +1,149 @@
+package jobworker
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForArray polls the array's status until every job has completed
+func waitForArray(t *testing.T, a *Array) ArrayStatus {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if status := a.Status(); status.State != WorkflowRunning {
+			return status
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected array to complete, actual %+v", a.Status())
+	return ArrayStatus{}
+}
+
+func TestArray_Sets_Index_And_Size_In_Env(t *testing.T) {
+	mockUserId()
+	a, err := NewArray(&groupsController{}, "alice", 3, 0, Metadata{}, JobOpts{}, startJob, cmd, "-c", "echo $JOB_ARRAY_INDEX/$JOB_ARRAY_SIZE")
+	if err != nil {
+		t.Fatal("failed to start array: ", err)
+	}
+	if status := waitForArray(t, a); status.State != WorkflowSucceeded || len(status.Jobs) != 3 {
+		t.Fatalf("expected array of 3 jobs to succeed, actual %+v", status)
+	}
+	for i, job := range a.Jobs() {
+		reader, err := job.Output(DontFollowLogs)
+		if err != nil {
+			t.Fatal("could not get reader for job's output")
+		}
+		logs, _ := io.ReadAll(reader)
+		reader.Close()
+		if expected := []string{"0/3", "1/3", "2/3"}[i]; strings.TrimSpace(string(logs)) != expected {
+			t.Errorf("expected job %d to log %q, actual %q", i, expected, logs)
+		}
+	}
+}
+
+func TestArray_Limits_Parallel_Jobs(t *testing.T) {
+	mockUserId()
+	a, err := NewArray(&groupsController{}, "alice", 4, 2, Metadata{}, JobOpts{}, startJob, cmd, "-c", "sleep 0.2")
+	if err != nil {
+		t.Fatal("failed to start array: ", err)
+	}
+	// Assert no more than 2 jobs run at once, and the jobs waiting for a slot are pending
+	throttled := false
+	for i := 0; i < 10; i++ {
+		running, pending := 0, 0
+		for _, job := range a.Status().Jobs {
+			if job.Running {
+				running++
+			} else if job.Pending {
+				pending++
+			}
+		}
+		if running > 2 {
+			t.Fatalf("expected at most 2 jobs to run at once, actual %d", running)
+		}
+		throttled = throttled || (running == 2 && pending == 2)
+		time.Sleep(40 * time.Millisecond)
+	}
+	if !throttled {
+		t.Error("expected 2 jobs to be pending while 2 jobs were running")
+	}
+	if status := waitForArray(t, a); status.State != WorkflowSucceeded {
+		t.Errorf("expected array to succeed, actual %+v", status)
+	}
+}
+
+func TestArray_Stop_Cancels_Pending_Jobs(t *testing.T) {
+	mockUserId()
+	con := &groupsController{}
+	a, err := NewArray(con, "alice", 3, 1, Metadata{}, JobOpts{}, startJob, cmd, "-c", "sleep 30")
+	if err != nil {
+		t.Fatal("failed to start array: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, job := range a.Jobs() {
+		if err = job.Stop(ctx); err != nil {
+			t.Errorf("expected to be able to stop job %s, error: %v", job.ID, err)
+		}
+	}
+	status := waitForArray(t, a)
+	if status.State != WorkflowFailed {
+		t.Errorf("expected stopped array to fail, actual %s", status.State)
+	}
+	for i, job := range status.Jobs {
+		if job.ExitReason != Stopped {
+			t.Errorf("expected job %d to be stopped, actual %+v", i, job)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if jobs := a.Jobs(); con.created(jobs[1].ID) || con.created(jobs[2].ID) {
+		t.Error("expected cancelled jobs to not be started once a slot was free")
+	}
+}
+
+func TestArray_Output_Prefixes_Lines_With_Index(t *testing.T) {
+	mockUserId()
+	a, err := NewArray(&groupsController{}, "alice", 3, 1, Metadata{}, JobOpts{}, startJob, cmd, "-c", "echo first $JOB_ARRAY_INDEX; sleep 0.1; echo second $JOB_ARRAY_INDEX")
+	if err != nil {
+		t.Fatal("failed to start array: ", err)
+	}
+	// Assert following the logs waits for pending jobs to start, and ends once every job has completed
+	reader := a.Output(FollowLogs)
+	followed, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal("failed to follow the array's logs: ", err)
+	}
+	// Lines of different jobs are read as they're written, so only each job's lines are in order
+	lines := strings.SplitAfter(string(followed), "\n")
+	slices.SortStableFunc(lines, func(a, b string) int {
+		return strings.Compare(a[:min(3, len(a))], b[:min(3, len(b))])
+	})
+	expected := "[0] first 0\n[0] second 0\n[1] first 1\n[1] second 1\n[2] first 2\n[2] second 2\n"
+	if strings.Join(lines, "") != expected {
+		t.Errorf("expected followed logs %q, actual %q", expected, followed)
+	}
+	// Assert the logs of completed jobs are read in index order
+	reader = a.Output(DontFollowLogs)
+	logs, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(logs) != expected {
+		t.Errorf("expected logs %q, actual %q", expected, logs)
+	}
+}
+
+func TestArray_Rejects_Invalid_Size(t *testing.T) {
+	for _, size := range []int{0, MAX_ARRAY_SIZE + 1} {
+		if _, err := NewArray(&groupsController{}, "alice", size, 0, Metadata{}, JobOpts{}, startJob, cmd); err == nil {
+			t.Errorf("expected array of size %d to be rejected", size)
+		}
+	}
+	for _, parallel := range []int{-1, 3} {
+		if _, err := NewArray(&groupsController{}, "alice", 2, parallel, Metadata{}, JobOpts{}, startJob, cmd); err == nil {
+			t.Errorf("expected parallelism of %d to be rejected for an array of 2", parallel)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package jobworker
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // cgroup v2 interface files for supported controllers
@@ -15,6 +19,24 @@ const (
 	cpuWeightFile = "cpu.weight"
 	memHighFile   = "memory.high"
 	ioWeightFile  = "io.weight"
+	cpuMaxFile    = "cpu.max"
+	cpusetFile    = "cpuset.cpus"
+	killFile      = "cgroup.kill"
+	procsFile     = "cgroup.procs"
+	eventsFile    = "cgroup.events"
+	memEventsFile = "memory.events"
+)
+
+// ErrCgroupPopulated is returned when a cgroup still has running processes
+var ErrCgroupPopulated = errors.New("cgroup is still populated")
+
+// CPUMaxPeriod is the cpu.max period in microseconds, JobOpts.CPUMax millicores are converted to a quota of this period
+const CPUMaxPeriod = 100000
+
+// killProcs retry settings used when cgroup.kill is not available
+const (
+	killMaxAttempts   = 10
+	killRetryInterval = 10 * time.Millisecond
 )
 
 // Cgroup implements ResourceController and provides a minimal interface for the host's cgroup
@@ -43,10 +65,152 @@ func (cg *Cgroup) CreateGroup(name string) (err error) {
 	return os.Mkdir(cg.groupPath(name), 0755)
 }
 
-// DeleteGroup deletes a cgroup's directory signalling cgroup to delete the group
-// TODO in production before deleting a group we could check cgroup.events to ensure no processes are still running in their cgroup
+// DeleteGroup deletes a cgroup's directory signalling cgroup to delete the group. The kernel refuses to remove a group
+// with EBUSY while processes are still exiting, so we wait for cgroup.events to report `populated 0` and retry the
+// removal until CGROUP_DELETE_TIMEOUT
 func (cg *Cgroup) DeleteGroup(name string) error {
-	return os.RemoveAll(cg.groupPath(name))
+	return removeGroup(cg.groupPath(name), func() (bool, error) { return cg.populated(name) })
+}
+
+// rmdirGroup removes an empty cgroup directory, on cgroupfs it's interface files are removed along with it
+var rmdirGroup = syscall.Rmdir
+
+// removeGroup removes a cgroup directory once populated reports no processes are running, retrying until
+// CGROUP_DELETE_TIMEOUT. A group that doesn't exist has already been removed, e.g. by a previous call once the job
+// was stopped, so it isn't an error
+func removeGroup(path string, populated func() (bool, error)) error {
+	deadline := time.Now().Add(CGROUP_DELETE_TIMEOUT)
+	for {
+		isPopulated, err := populated()
+		if err == nil && isPopulated {
+			err = ErrCgroupPopulated
+		}
+		if err == nil {
+			err = rmdirGroup(path)
+		}
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("failed to delete cgroup %s: %w", path, err)
+		}
+		time.Sleep(CGROUP_DELETE_POLL_INTERVAL)
+	}
+}
+
+// populated reads a cgroup's cgroup.events file and returns true if processes are still running in the group or
+// it's descendants. If the group or it's events file does not exist, it's treated as not populated
+func (cg *Cgroup) populated(name string) (bool, error) {
+	b, err := os.ReadFile(filepath.Join(cg.groupPath(name), eventsFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "populated" {
+			return fields[1] == "1", nil
+		}
+	}
+	return false, nil
+}
+
+// OOMKills reads a cgroup's memory.events file and returns the number of processes killed by the OOM killer
+func (cg *Cgroup) OOMKills(name string) (int, error) {
+	b, err := os.ReadFile(filepath.Join(cg.groupPath(name), memEventsFile))
+	if err != nil {
+		return 0, err
+	}
+	return ParseOOMKills(string(b))
+}
+
+// ParseOOMKills returns the oom_kill count from the content of a cgroup v2 memory.events file
+func ParseOOMKills(memoryEvents string) (int, error) {
+	scanner := bufio.NewScanner(strings.NewReader(memoryEvents))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.Atoi(fields[1])
+		}
+	}
+	return 0, nil
+}
+
+// KillGroup sends SIGKILL to every process in a cgroup, including any that have left the job's process group. On kernels
+// that support it (5.14+) this is done atomically by writing to cgroup.kill, otherwise we fall back to signalling each PID
+// listed in cgroup.procs. A cgroup that has already been removed has nothing left to kill, so isn't an error
+func (cg *Cgroup) KillGroup(name string) error {
+	killPath := filepath.Join(cg.groupPath(name), killFile)
+	if _, err := os.Stat(killPath); err == nil {
+		return os.WriteFile(killPath, []byte("1"), 0644)
+	}
+	if err := killProcs(filepath.Join(cg.groupPath(name), procsFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SignalGroup sends a signal to every process in a cgroup, including any that have left the job's process group
+func (cg *Cgroup) SignalGroup(name string, sig syscall.Signal) error {
+	return signalProcs(filepath.Join(cg.groupPath(name), procsFile), sig)
+}
+
+// signalProcs signals each PID in a cgroup.procs file once, ignoring processes that have already exited
+func signalProcs(procsPath string, sig syscall.Signal) error {
+	pids, err := readProcs(procsPath)
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		if err = syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to signal PID %d: %w", pid, err)
+		}
+	}
+	return nil
+}
+
+// killProcs SIGKILL's each PID in a cgroup.procs file. Since a process could fork between reading the file and
+// signalling, we repeat until a pass finds no live processes or we give up after killMaxAttempts
+func killProcs(procsPath string) error {
+	for range killMaxAttempts {
+		pids, err := readProcs(procsPath)
+		if err != nil {
+			return err
+		}
+		alive := 0
+		for _, pid := range pids {
+			err = syscall.Kill(pid, syscall.SIGKILL)
+			if err == nil {
+				alive++
+			} else if err != syscall.ESRCH {
+				return fmt.Errorf("failed to kill PID %d: %w", pid, err)
+			}
+		}
+		if alive == 0 {
+			return nil
+		}
+		time.Sleep(killRetryInterval)
+	}
+	return fmt.Errorf("processes still running in %s after %d attempts", procsPath, killMaxAttempts)
+}
+
+// readProcs returns the PIDs listed in a cgroup.procs file
+func readProcs(procsPath string) ([]int, error) {
+	b, err := os.ReadFile(procsPath)
+	if err != nil {
+		return nil, err
+	}
+	pids := []int{}
+	for _, line := range strings.Fields(string(b)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse PID in %s: %w", procsPath, err)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
 }
 
 // updateController sets the content of the controller interface file for a
@@ -56,7 +220,7 @@ func (cg *Cgroup) updateController(name string, file, val string) error {
 }
 
 // AddResourceControl updates the resource control interface file for a given cgroup using JobOpts. The
-// three currently supported are CPU, memory and IO
+// three currently supported are CPU, memory and IO. CPU can optionally be capped by cpu.max and pinned with cpuset.cpus
 func (cg *Cgroup) AddResourceControl(name string, opts JobOpts) (err error) {
 	if err = cg.updateController(name, cpuWeightFile, fmt.Sprintf("%d", opts.CPUWeight)); err != nil {
 		return err
@@ -64,9 +228,31 @@ func (cg *Cgroup) AddResourceControl(name string, opts JobOpts) (err error) {
 	if err = cg.updateController(name, memHighFile, fmt.Sprintf("%d", opts.MemLimit)); err != nil {
 		return err
 	}
+	if opts.CPUMax > 0 {
+		quota := int64(opts.CPUMax) * CPUMaxPeriod / 1000
+		if err = cg.updateController(name, cpuMaxFile, fmt.Sprintf("%d %d", quota, CPUMaxPeriod)); err != nil {
+			return err
+		}
+	}
+	if opts.CPUSet != "" {
+		if err = cg.updateController(name, cpusetFile, opts.CPUSet); err != nil {
+			return err
+		}
+	}
 	return cg.updateController(name, ioWeightFile, fmt.Sprintf("%d", opts.IOWeight))
 }
 
+// Capabilities reports that all of the resource controls are enforced by the cgroup v2 controllers
+func (cg *Cgroup) Capabilities(opts JobOpts) Capabilities {
+	return Capabilities{
+		cpuWeightFile: Enforced,
+		ioWeightFile:  Enforced,
+		memHighFile:   Enforced,
+		cpuMaxFile:    Enforced,
+		cpusetFile:    Enforced,
+	}
+}
+
 // groupPath returns a given cgroup's directory path identified by name
 func (cg *Cgroup) groupPath(name string) string {
 	return filepath.Join(cg.rootPath, name)
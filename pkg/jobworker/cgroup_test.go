@@ -1,9 +1,14 @@
 package jobworker
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 )
 
 const testName = "TEST"
@@ -20,12 +25,29 @@ func exists(path string) (bool, error) {
 	return false, err
 }
 
+// Tests emulate cgroupfs in a tmp dir, where a group's interface files are regular files that must be removed before
+// the group's directory
+func init() {
+	rmdirGroup = func(path string) error {
+		files, err := filepath.Glob(filepath.Join(path, "*"))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err = os.Remove(file); err != nil {
+				return err
+			}
+		}
+		return syscall.Rmdir(path)
+	}
+}
+
 func TestCgroupController(t *testing.T) {
 	// Set up Cgroup to test with test tmp dir
 	tmpDir := t.TempDir()
 	testDir := filepath.Join(tmpDir, testName)
 	cgroup := Cgroup{tmpDir}
-	testOpts := JobOpts{100, 50, 100 * CgroupMB}
+	testOpts := JobOpts{CPUWeight: 100, IOWeight: 50, MemLimit: 100 * CgroupMB}
 	// TEST CreateGroup
 	err := cgroup.CreateGroup(testName)
 	if err != nil {
@@ -88,3 +110,159 @@ func TestCgroupController(t *testing.T) {
 		t.Error("Expected /tmp/TEST NOT to exist to represent cgroup")
 	}
 }
+
+func TestCgroupController_CPU_Max_And_CPUSet(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := filepath.Join(tmpDir, testName)
+	cgroup := Cgroup{tmpDir}
+	if err := cgroup.CreateGroup(testName); err != nil {
+		t.Fatalf("could not create cgroup: %v", err)
+	}
+	if err := cgroup.AddResourceControl(testName, JobOpts{CPUMax: 1500, CPUSet: "0-1"}); err != nil {
+		t.Fatalf("could not add resource controls to cgroup controller: %v", err)
+	}
+	// 1.5 CPUs is 150ms of every 100ms period
+	cpuMax, err := os.ReadFile(filepath.Join(testDir, cpuMaxFile))
+	if err != nil {
+		t.Fatalf("could not read cpu.max: %v", err)
+	}
+	if string(cpuMax) != "150000 100000" {
+		t.Errorf("cpu.max is incorrect: %s", string(cpuMax))
+	}
+	cpuset, err := os.ReadFile(filepath.Join(testDir, cpusetFile))
+	if err != nil {
+		t.Fatalf("could not read cpuset.cpus: %v", err)
+	}
+	if string(cpuset) != "0-1" {
+		t.Errorf("cpuset.cpus is incorrect: %s", string(cpuset))
+	}
+}
+
+func TestCgroupKillGroup_Uses_Cgroup_Kill(t *testing.T) {
+	tmpDir := t.TempDir()
+	cgroup := Cgroup{tmpDir}
+	if err := cgroup.CreateGroup(testName); err != nil {
+		t.Fatalf("could not create cgroup: %v", err)
+	}
+	// Emulate a kernel that supports cgroup.kill
+	killPath := filepath.Join(tmpDir, testName, killFile)
+	if err := os.WriteFile(killPath, []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cgroup.KillGroup(testName); err != nil {
+		t.Fatalf("could not kill cgroup: %v", err)
+	}
+	kill, err := os.ReadFile(killPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(kill) != "1" {
+		t.Errorf("expected cgroup.kill to be 1 but was %s", string(kill))
+	}
+}
+
+func TestCgroupKillGroup_Falls_Back_To_Procs(t *testing.T) {
+	tmpDir := t.TempDir()
+	cgroup := Cgroup{tmpDir}
+	if err := cgroup.CreateGroup(testName); err != nil {
+		t.Fatalf("could not create cgroup: %v", err)
+	}
+	// Start a process in a new session to emulate one escaping the job's process group
+	proc := exec.Command("sleep", "100")
+	proc.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- proc.Wait() }()
+	// Emulate a kernel without cgroup.kill where the process is listed in cgroup.procs
+	procsPath := filepath.Join(tmpDir, testName, procsFile)
+	if err := os.WriteFile(procsPath, []byte(fmt.Sprintf("%d\n", proc.Process.Pid)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cgroup.KillGroup(testName); err != nil {
+		t.Fatalf("could not kill cgroup: %v", err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Error("expected process in cgroup.procs to be killed")
+		proc.Process.Kill()
+	}
+}
+
+func TestCgroupKillGroup_Ignores_Removed_Group(t *testing.T) {
+	cgroup := Cgroup{t.TempDir()}
+	if err := cgroup.KillGroup(testName); err != nil {
+		t.Errorf("expected killing a removed cgroup to succeed, actual %v", err)
+	}
+}
+
+func TestCgroupSignalGroup_Signals_Procs(t *testing.T) {
+	tmpDir := t.TempDir()
+	cgroup := Cgroup{tmpDir}
+	if err := cgroup.CreateGroup(testName); err != nil {
+		t.Fatalf("could not create cgroup: %v", err)
+	}
+	// Start a process in a new session to emulate one escaping the job's process group
+	proc := exec.Command("sleep", "100")
+	proc.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- proc.Wait() }()
+	procsPath := filepath.Join(tmpDir, testName, procsFile)
+	if err := os.WriteFile(procsPath, []byte(fmt.Sprintf("%d\n", proc.Process.Pid)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cgroup.SignalGroup(testName, syscall.SIGTERM); err != nil {
+		t.Fatalf("could not signal cgroup: %v", err)
+	}
+	select {
+	case err := <-exited:
+		if status, ok := err.(*exec.ExitError); !ok || status.Sys().(syscall.WaitStatus).Signal() != syscall.SIGTERM {
+			t.Errorf("expected process to be terminated by SIGTERM, actual %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected process in cgroup.procs to be signalled")
+		proc.Process.Kill()
+	}
+}
+
+func TestCgroupDeleteGroup_Waits_For_Populated(t *testing.T) {
+	CGROUP_DELETE_TIMEOUT = 100 * time.Millisecond
+	CGROUP_DELETE_POLL_INTERVAL = 10 * time.Millisecond
+	tmpDir := t.TempDir()
+	testDir := filepath.Join(tmpDir, testName)
+	cgroup := Cgroup{tmpDir}
+	if err := cgroup.CreateGroup(testName); err != nil {
+		t.Fatalf("could not create cgroup: %v", err)
+	}
+	// Emulate a cgroup with processes still running
+	eventsPath := filepath.Join(testDir, eventsFile)
+	if err := os.WriteFile(eventsPath, []byte("populated 1\nfrozen 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := cgroup.DeleteGroup(testName)
+	if !errors.Is(err, ErrCgroupPopulated) {
+		t.Errorf("expected populated cgroup error but got: %v", err)
+	}
+	if exist, _ := exists(testDir); !exist {
+		t.Error("expected populated cgroup not to be deleted")
+	}
+	// Once the processes exit the group can be deleted
+	if err = os.WriteFile(eventsPath, []byte("populated 0\nfrozen 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = cgroup.DeleteGroup(testName); err != nil {
+		t.Errorf("could not delete cgroup: %v", err)
+	}
+	if exist, _ := exists(testDir); exist {
+		t.Error("expected cgroup to be deleted")
+	}
+	// Deleting a group that was already deleted succeeds
+	if err = cgroup.DeleteGroup(testName); err != nil {
+		t.Errorf("expected deleting a deleted cgroup to succeed: %v", err)
+	}
+}
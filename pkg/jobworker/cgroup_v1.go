@@ -0,0 +1,244 @@
+package jobworker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// cgroup v1 interface files for supported controllers
+const (
+	cpuSharesFile    = "cpu.shares"
+	cpuQuotaFile     = "cpu.cfs_quota_us"
+	cpuPeriodFile    = "cpu.cfs_period_us"
+	memSoftLimitFile = "memory.soft_limit_in_bytes"
+	memLimitFile     = "memory.limit_in_bytes"
+	blkioWeightFile  = "blkio.weight"
+	cpusetMemsFile   = "cpuset.mems"
+)
+
+// v1Controllers are the cgroup v1 controllers CgroupV1 creates groups in
+var v1Controllers = []string{"cpu", "memory", "blkio", "cpuset"}
+
+// CgroupV1 implements ResourceController for hosts running the legacy cgroup v1 hierarchy, where each controller is
+// mounted separately, e.g. /sys/fs/cgroup/memory
+type CgroupV1 struct {
+	mounts map[string]string // mount point key'd by controller
+}
+
+// NewCgroupV1 initialises a CgroupV1 given the mount point of each controller
+func NewCgroupV1(mounts map[string]string) *CgroupV1 {
+	return &CgroupV1{mounts: mounts}
+}
+
+// AddProcess prepares the cmd to be started without a cgroup, cgroup v1 does not support CLONE_INTO_CGROUP so the
+// process is added to the group by EnrollProcess once it has started
+func (cg *CgroupV1) AddProcess(name string, cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CgroupFD: -1}
+	return nil
+}
+
+// EnrollProcess writes the started process's PID to the group's cgroup.procs for each controller, the process is held
+// until it returns so the resource controls are active before the job's command runs
+func (cg *CgroupV1) EnrollProcess(name string, pid int) error {
+	for _, controller := range cg.controllers() {
+		procs := filepath.Join(cg.groupPath(controller, name), procsFile)
+		if err := os.WriteFile(procs, []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateGroup creates a directory in each controller's hierarchy. A cpuset group must have CPUs and memory nodes before
+// processes can be added so they are copied from the parent
+func (cg *CgroupV1) CreateGroup(name string) error {
+	for _, controller := range cg.controllers() {
+		path := cg.groupPath(controller, name)
+		if err := os.Mkdir(path, 0755); err != nil {
+			return err
+		}
+		if controller == "cpuset" {
+			for _, file := range []string{cpusetFile, cpusetMemsFile} {
+				if err := copyFile(filepath.Join(cg.mounts[controller], file), filepath.Join(path, file)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteGroup deletes the group's directory in each controller's hierarchy, waiting for the group's cgroup.procs to be
+// empty since cgroup v1 does not have cgroup.events
+func (cg *CgroupV1) DeleteGroup(name string) error {
+	for _, controller := range cg.controllers() {
+		path := cg.groupPath(controller, name)
+		err := removeGroup(path, func() (bool, error) {
+			pids, err := readProcs(filepath.Join(path, procsFile))
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return len(pids) > 0, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KillGroup SIGKILL's every process in the group. cgroup v1 does not have cgroup.kill so we signal each PID in
+// cgroup.procs, since the process is added to every controller any hierarchy lists all of the group's processes
+func (cg *CgroupV1) KillGroup(name string) error {
+	controllers := cg.controllers()
+	if len(controllers) == 0 {
+		return nil
+	}
+	return killProcs(filepath.Join(cg.groupPath(controllers[0], name), procsFile))
+}
+
+// SignalGroup sends a signal to every process in the group
+func (cg *CgroupV1) SignalGroup(name string, sig syscall.Signal) error {
+	controllers := cg.controllers()
+	if len(controllers) == 0 {
+		return nil
+	}
+	return signalProcs(filepath.Join(cg.groupPath(controllers[0], name), procsFile), sig)
+}
+
+// AddResourceControl maps JobOpts to the cgroup v1 interface files. Weights are scaled from the cgroup v2 ranges,
+// cpu.weight (default 100) to cpu.shares (default 1024) and io.weight (default 100) to blkio.weight (default 500).
+// There is no equivalent to memory.high, so the limit is used as both the soft limit and hard limit
+func (cg *CgroupV1) AddResourceControl(name string, opts JobOpts) (err error) {
+	if err = cg.updateController("cpu", name, cpuSharesFile, fmt.Sprintf("%d", max(int64(opts.CPUWeight)*1024/100, 2))); err != nil {
+		return err
+	}
+	if opts.CPUMax > 0 {
+		if err = cg.updateController("cpu", name, cpuPeriodFile, fmt.Sprintf("%d", CPUMaxPeriod)); err != nil {
+			return err
+		}
+		quota := int64(opts.CPUMax) * CPUMaxPeriod / 1000
+		if err = cg.updateController("cpu", name, cpuQuotaFile, fmt.Sprintf("%d", quota)); err != nil {
+			return err
+		}
+	}
+	if opts.MemLimit > 0 {
+		if err = cg.updateController("memory", name, memSoftLimitFile, opts.MemLimit.String()); err != nil {
+			return err
+		}
+		if err = cg.updateController("memory", name, memLimitFile, opts.MemLimit.String()); err != nil {
+			return err
+		}
+	}
+	if opts.CPUSet != "" {
+		if err = cg.updateController("cpuset", name, cpusetFile, opts.CPUSet); err != nil {
+			return err
+		}
+	}
+	// blkio.weight only exists when the host's IO scheduler supports it (CFQ or BFQ)
+	if _, err = os.Stat(filepath.Join(cg.groupPath("blkio", name), blkioWeightFile)); os.IsNotExist(err) {
+		return nil
+	}
+	blkioWeight := min(max(int64(opts.IOWeight)*500/100, 10), 1000)
+	return cg.updateController("blkio", name, blkioWeightFile, fmt.Sprintf("%d", blkioWeight))
+}
+
+// Capabilities reports the resource controls are enforced, except IO weight if the host's IO scheduler does not
+// support blkio.weight
+func (cg *CgroupV1) Capabilities(opts JobOpts) Capabilities {
+	caps := Capabilities{
+		cpuWeightFile: Enforced,
+		ioWeightFile:  Enforced,
+		memHighFile:   Enforced,
+		cpuMaxFile:    Enforced,
+		cpusetFile:    Enforced,
+	}
+	if _, err := os.Stat(filepath.Join(cg.mounts["blkio"], blkioWeightFile)); err != nil {
+		caps[ioWeightFile] = Unsupported
+	}
+	return caps
+}
+
+// updateController sets the content of a controller's interface file, skipping controllers that aren't mounted
+func (cg *CgroupV1) updateController(controller, name, file, val string) error {
+	if _, ok := cg.mounts[controller]; !ok {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(cg.groupPath(controller, name), file), []byte(val), 0644)
+}
+
+// controllers returns the supported controllers that are mounted
+func (cg *CgroupV1) controllers() []string {
+	controllers := []string{}
+	for _, controller := range v1Controllers {
+		if _, ok := cg.mounts[controller]; ok {
+			controllers = append(controllers, controller)
+		}
+	}
+	return controllers
+}
+
+// groupPath returns a given cgroup's directory path within a controller's hierarchy
+func (cg *CgroupV1) groupPath(controller, name string) string {
+	return filepath.Join(cg.mounts[controller], name)
+}
+
+// copyFile copies the contents of src to dst, skipping if src does not exist
+func copyFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, 0644)
+}
+
+// DetectController inspects a mountinfo file, typically /proc/self/mountinfo, and returns a CgroupV1 if any of the
+// supported controllers are mounted on the legacy hierarchy, otherwise a Cgroup for the unified cgroup v2 hierarchy
+func DetectController(mountinfo string) (ResourceController, error) {
+	f, err := os.Open(mountinfo)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	v1Mounts := map[string]string{}
+	v2Mount := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// e.g. 36 32 0:32 / /sys/fs/cgroup/memory rw,relatime - cgroup cgroup rw,memory
+		fields := strings.Fields(scanner.Text())
+		sep := slices.Index(fields, "-")
+		if sep < 5 || len(fields) < sep+4 {
+			continue
+		}
+		mountPoint, fsType, superOpts := fields[4], fields[sep+1], strings.Split(fields[sep+3], ",")
+		switch fsType {
+		case "cgroup2":
+			v2Mount = mountPoint
+		case "cgroup":
+			for _, controller := range v1Controllers {
+				if slices.Contains(superOpts, controller) {
+					v1Mounts[controller] = mountPoint
+				}
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(v1Mounts) > 0 {
+		return NewCgroupV1(v1Mounts), nil
+	}
+	if v2Mount != "" {
+		return &Cgroup{v2Mount}, nil
+	}
+	return nil, fmt.Errorf("no cgroup hierarchy found in %s", mountinfo)
+}
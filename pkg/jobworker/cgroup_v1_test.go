@@ -0,0 +1,160 @@
+package jobworker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCgroupV1 creates a CgroupV1 with each controller "mounted" in the test's tmp dir
+func newTestCgroupV1(t *testing.T) (*CgroupV1, string) {
+	tmpDir := t.TempDir()
+	mounts := map[string]string{}
+	for _, controller := range v1Controllers {
+		mounts[controller] = filepath.Join(tmpDir, controller)
+		if err := os.Mkdir(mounts[controller], 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The root cpuset has all of the host's CPUs and memory nodes
+	if err := os.WriteFile(filepath.Join(mounts["cpuset"], cpusetMemsFile), []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return NewCgroupV1(mounts), tmpDir
+}
+
+func TestCgroupV1Controller(t *testing.T) {
+	mockUserId()
+	cgroup, tmpDir := newTestCgroupV1(t)
+	testOpts := JobOpts{CPUWeight: 200, IOWeight: 50, MemLimit: 100 * CgroupMB, CPUMax: 500, CPUSet: "1"}
+	// TEST CreateGroup
+	if err := cgroup.CreateGroup(testName); err != nil {
+		t.Fatalf("could not create cgroup: %v", err)
+	}
+	for _, controller := range v1Controllers {
+		if exist, err := exists(filepath.Join(tmpDir, controller, testName)); !exist || err != nil {
+			t.Errorf("expected %s cgroup to exist", controller)
+		}
+	}
+	// Emulate a host IO scheduler that supports blkio.weight
+	if err := os.WriteFile(filepath.Join(tmpDir, "blkio", testName, blkioWeightFile), []byte("500"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// TEST AddResourceControl and assert the interface files of each controller
+	if err := cgroup.AddResourceControl(testName, testOpts); err != nil {
+		t.Fatalf("could not add resource controls to cgroup controller: %v", err)
+	}
+	expected := map[string]string{
+		filepath.Join("cpu", testName, cpuSharesFile):       "2048",
+		filepath.Join("cpu", testName, cpuQuotaFile):        "50000",
+		filepath.Join("cpu", testName, cpuPeriodFile):       "100000",
+		filepath.Join("memory", testName, memSoftLimitFile): "104857600",
+		filepath.Join("memory", testName, memLimitFile):     "104857600",
+		filepath.Join("blkio", testName, blkioWeightFile):   "250",
+		filepath.Join("cpuset", testName, cpusetFile):       "1",
+		filepath.Join("cpuset", testName, cpusetMemsFile):   "0",
+	}
+	for file, value := range expected {
+		b, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Errorf("could not read %s: %v", file, err)
+			continue
+		}
+		if string(b) != value {
+			t.Errorf("%s is incorrect: expected %s actual %s", file, value, string(b))
+		}
+	}
+	// TEST DeleteGroup
+	if err := cgroup.DeleteGroup(testName); err != nil {
+		t.Fatalf("could not delete cgroup: %v", err)
+	}
+	for _, controller := range v1Controllers {
+		if exist, err := exists(filepath.Join(tmpDir, controller, testName)); exist || err != nil {
+			t.Errorf("expected %s cgroup NOT to exist", controller)
+		}
+	}
+}
+
+func TestCgroupV1EnrollProcess(t *testing.T) {
+	mockUserId()
+	cgroup, tmpDir := newTestCgroupV1(t)
+	// Run a job and assert it's PID was written to each controller by the worker before it's command ran
+	job, err := StartWithController(cgroup, JobOpts{}, "echo", "hello")
+	if err != nil {
+		t.Fatalf("could not start job: %v", err)
+	}
+	defer os.Remove(logPath(job.ID))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status, err := job.Wait(ctx)
+	if err != nil || status.ExitCode != 0 {
+		t.Fatalf("expected job to exit successfully, actual %+v error %v", status, err)
+	}
+	for _, controller := range v1Controllers {
+		procs, err := os.ReadFile(filepath.Join(tmpDir, controller, job.ID, procsFile))
+		if err != nil {
+			t.Fatalf("could not read %s cgroup.procs: %v", controller, err)
+		}
+		if strings.TrimSpace(string(procs)) != fmt.Sprintf("%d", status.PID) {
+			t.Errorf("expected %s cgroup.procs to contain %d, actual %s", controller, status.PID, string(procs))
+		}
+	}
+	reader, err := job.Output(DontFollowLogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if out, _ := io.ReadAll(reader); string(out) != "hello\n" {
+		t.Errorf("expected job's output to be hello, actual %s", string(out))
+	}
+}
+
+func TestDetectController(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Legacy hierarchy with cpu and cpuacct mounted together
+	v1 := filepath.Join(tmpDir, "mountinfo_v1")
+	err := os.WriteFile(v1, []byte(`24 1 0:22 / /sys rw,nosuid - sysfs sysfs rw
+32 24 0:28 / /sys/fs/cgroup rw,relatime - tmpfs tmpfs rw,mode=755
+33 32 0:29 / /sys/fs/cgroup/cpu,cpuacct rw,relatime shared:9 - cgroup cgroup rw,cpu,cpuacct
+36 32 0:32 / /sys/fs/cgroup/memory rw,relatime shared:12 - cgroup cgroup rw,memory
+42 32 0:38 / /sys/fs/cgroup/unified rw,relatime - cgroup2 cgroup2 rw
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	con, err := DetectController(v1)
+	if err != nil {
+		t.Fatalf("could not detect controller: %v", err)
+	}
+	cgroupV1, ok := con.(*CgroupV1)
+	if !ok {
+		t.Fatalf("expected CgroupV1 controller, actual %T", con)
+	}
+	if cgroupV1.mounts["cpu"] != "/sys/fs/cgroup/cpu,cpuacct" || cgroupV1.mounts["memory"] != "/sys/fs/cgroup/memory" {
+		t.Errorf("controller mounts are incorrect: %v", cgroupV1.mounts)
+	}
+	// Unified hierarchy
+	v2 := filepath.Join(tmpDir, "mountinfo_v2")
+	err = os.WriteFile(v2, []byte(`24 1 0:22 / /sys rw,nosuid - sysfs sysfs rw
+35 24 0:30 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw,nsdelegate
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	con, err = DetectController(v2)
+	if err != nil {
+		t.Fatalf("could not detect controller: %v", err)
+	}
+	cgroupV2, ok := con.(*Cgroup)
+	if !ok {
+		t.Fatalf("expected Cgroup controller, actual %T", con)
+	}
+	if cgroupV2.rootPath != "/sys/fs/cgroup" {
+		t.Errorf("expected cgroup root to be /sys/fs/cgroup, actual %s", cgroupV2.rootPath)
+	}
+}
@@ -21,7 +21,7 @@ func TestConcurrentReaders(t *testing.T) {
 	mockUserId()
 	// Define job's command and options for test
 	args := []string{"-c", fmt.Sprintf("for run in {1..%d}; do echo ${run}: %s; sleep 0.01; done", n, echo)}
-	opts := JobOpts{100, 50, 100 * CgroupMB}
+	opts := JobOpts{CPUWeight: 100, IOWeight: 50, MemLimit: 100 * CgroupMB}
 	// Run the job
 	job, err := StartWithController(&mockController{}, opts, cmd, args...)
 	if err != nil {
@@ -72,7 +72,7 @@ func TestConcurrentReadersNoFollow(t *testing.T) {
 	mockUserId()
 	// Define job's command and options for test
 	args := []string{"-c", fmt.Sprintf("for run in {1..%d}; do echo ${run}: %s; sleep 0.01; done", n, echo)}
-	opts := JobOpts{100, 50, 100 * CgroupMB}
+	opts := JobOpts{CPUWeight: 100, IOWeight: 50, MemLimit: 100 * CgroupMB}
 	// Run the job
 	job, err := StartWithController(&mockController{}, opts, cmd, args...)
 	if err != nil {
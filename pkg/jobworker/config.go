@@ -1,6 +1,9 @@
 package jobworker
 
-import "time"
+import (
+	"syscall"
+	"time"
+)
 
 // TODO In production I would extrapolate these from the environment, possible with something like https://github.com/kelseyhightower/envconfig
 var (
@@ -9,6 +12,58 @@ var (
 	STOP_POLL_INTERVAL = time.Second
 	RPC_CLIENT_TIMEOUT = 10 * time.Second
 	RPC_STREAM_TIMEOUT = 10 * time.Minute
+	// Number of jobs returned by a List request that doesn't give a page size, and the most it can request
+	LIST_PAGE_SIZE     = 50
+	LIST_MAX_PAGE_SIZE = 500
 	WORKER_UID         = 1000
 	WORKER_GID         = 1000
+	// Cgroup deletion waits for the group to be empty, polling cgroup.events until the timeout
+	CGROUP_DELETE_TIMEOUT       = 5 * time.Second
+	CGROUP_DELETE_POLL_INTERVAL = 50 * time.Millisecond
+	// Maximum number of cgroups and log files that couldn't be removed kept in the leak registry, the oldest are dropped
+	MAX_LEAKS = 1000
+	// Total CPU time a job without a Timeout can use when run by the unprivileged Rlimit controller, 0 for no limit
+	RLIMIT_CPU_TIME = time.Duration(0)
+	// Ratio of the host's memory and CPU that can be reserved by jobs, greater than 1 allows overcommitting
+	ADMISSION_OVERCOMMIT_RATIO = 1.0
+	// Common names of client certs permitted to call admin RPCs, such as listing leaked resources
+	ADMIN_OWNERS = []string{}
+	// Maximum runtime of an owner's jobs enforced by the server, owners not in OWNER_MAX_JOB_TIMEOUT use MAX_JOB_TIMEOUT.
+	// 0 for no limit
+	MAX_JOB_TIMEOUT       = time.Duration(0)
+	OWNER_MAX_JOB_TIMEOUT = map[string]time.Duration{}
+	// Delay before a job is restarted, doubling with each restart up to the max
+	RESTART_BACKOFF     = time.Second
+	RESTART_BACKOFF_MAX = 5 * time.Minute
+	// Number of times in a row a job's command can fail to be launched when restarting before the job fails
+	RESTART_MAX_LAUNCH_FAILURES = 5
+	// Maximum number of jobs running at once in total and per owner before jobs are queued, 0 for no limit. Queued
+	// jobs are started in QUEUE_ORDER, either "fifo" or "priority"
+	MAX_RUNNING_JOBS           = 0
+	MAX_RUNNING_JOBS_PER_OWNER = 0
+	QUEUE_ORDER                = "fifo"
+	// Number of recent job events kept for watchers to resume from, and how many events a watcher can fall behind
+	// before it's disconnected
+	EVENT_HISTORY      = 1000
+	EVENT_WATCH_BUFFER = 100
+	// Completed jobs are kept, along with their logs, until they're deleted or fall outside the retention policy: the
+	// RETAIN_JOBS_PER_OWNER most recently finished of each owner's jobs, for up to RETAIN_JOBS_FOR. 0 for no limit.
+	// Expired jobs are removed every REAP_INTERVAL
+	RETAIN_JOBS_PER_OWNER = 0
+	RETAIN_JOBS_FOR       = time.Duration(0)
+	REAP_INTERVAL         = time.Minute
+	// Maximum number of labels a job can have, and total size of it's annotations' keys and values
+	MAX_LABELS           = 32
+	MAX_ANNOTATIONS_SIZE = 64 * 1024
+	// How long the job started for a StartRequest's request ID is remembered, so a retried request returns the same
+	// job rather than starting a duplicate, and the maximum length of a request ID
+	REQUEST_ID_WINDOW     = time.Hour
+	MAX_REQUEST_ID_LENGTH = 128
+	// Maximum number of jobs in an Array
+	MAX_ARRAY_SIZE = 1000
+	// Signals owners are permitted to send to their jobs
+	ALLOWED_SIGNALS = []syscall.Signal{
+		syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGKILL,
+		syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH,
+	}
 )
@@ -0,0 +1,162 @@
+package jobworker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron determines when a Schedule launches it's jobs
+type Cron interface {
+	// Next returns the first time after t the schedule is due
+	Next(t time.Time) time.Time
+}
+
+// cronDescriptors are the predefined schedules that can be used in place of the 5 cron fields
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchLimit bounds the search for the next time a cron expression matches, i.e. for "0 0 30 2 *" which never does
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// ParseCron parses a standard 5 field cron expression "minute hour day-of-month month day-of-week", one of the
+// descriptors such as @daily or an interval such as "@every 10m". Fields support *, lists, ranges and steps,
+// e.g. "*/15 9-17 * * 1-5"
+func ParseCron(spec string) (Cron, error) {
+	spec = strings.TrimSpace(spec)
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, fmt.Errorf("cron %q not valid: %w", spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("cron %q not valid: interval must be at least 1s", spec)
+		}
+		return every(d), nil
+	}
+	if expr, ok := cronDescriptors[spec]; ok {
+		spec = expr
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q not valid: expected 5 fields", spec)
+	}
+	c := &cronExpr{}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("cron %q minute not valid: %w", spec, err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("cron %q hour not valid: %w", spec, err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("cron %q day of month not valid: %w", spec, err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("cron %q month not valid: %w", spec, err)
+	}
+	// Both 0 and 7 are Sunday
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("cron %q day of week not valid: %w", spec, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.anyDom, c.anyDow = fields[2] == "*", fields[4] == "*"
+	return c, nil
+}
+
+// every is a Cron that is due at a fixed interval
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// cronExpr is a parsed cron expression, each field is a bitset of the values it matches
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+	anyDom, anyDow                bool
+}
+
+func (c *cronExpr) Next(t time.Time) time.Time {
+	limit := t.Add(cronSearchLimit)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron's convention that if both day of month and day of week are restricted, either can match
+func (c *cronExpr) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if !c.anyDom && !c.anyDow {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// parseCronField parses a comma separated list of *, values, ranges and steps into a bitset of the values it matches
+func parseCronField(field string, low, high int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			var err error
+			if step, err = strconv.Atoi(after); err != nil || step < 1 {
+				return 0, fmt.Errorf("step %q not valid", after)
+			}
+			rng = before
+		}
+		start, end := low, high
+		if rng != "*" {
+			before, after, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = strconv.Atoi(before); err != nil {
+				return 0, fmt.Errorf("value %q not valid", before)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(after); err != nil {
+					return 0, fmt.Errorf("value %q not valid", after)
+				}
+			} else if step > 1 {
+				// e.g. 5/15 is every 15 starting at 5
+				end = high
+			}
+		}
+		if start < low || end > high || start > end {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, low, high)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
@@ -0,0 +1,53 @@
+package jobworker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Next(t *testing.T) {
+	// Saturday 2024-06-01 10:07:30
+	now := time.Date(2024, 6, 1, 10, 7, 30, 0, time.UTC)
+	tests := map[string]time.Time{
+		"* * * * *":        time.Date(2024, 6, 1, 10, 8, 0, 0, time.UTC),
+		"*/15 * * * *":     time.Date(2024, 6, 1, 10, 15, 0, 0, time.UTC),
+		"5/20 * * * *":     time.Date(2024, 6, 1, 10, 25, 0, 0, time.UTC),
+		"0 9-17 * * 1-5":   time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC),
+		"30 2 1,15 * *":    time.Date(2024, 6, 15, 2, 30, 0, 0, time.UTC),
+		"0 0 1 1 *":        time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		"0 12 13 * 5":      time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC),
+		"0 0 * * 7":        time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+		"@hourly":          time.Date(2024, 6, 1, 11, 0, 0, 0, time.UTC),
+		"@every 10m":       time.Date(2024, 6, 1, 10, 17, 30, 0, time.UTC),
+		"0 0 29 2 *":       time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		"  @daily  ":       time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+		"59 23 31 12 *":    time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC),
+		"0,30 8 * 6-7 0,6": time.Date(2024, 6, 2, 8, 0, 0, 0, time.UTC),
+	}
+	for spec, expected := range tests {
+		cron, err := ParseCron(spec)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", spec, err)
+			continue
+		}
+		if next := cron.Next(now); !next.Equal(expected) {
+			t.Errorf("expected %q to next be due at %s, actual %s", spec, expected, next)
+		}
+	}
+	// A valid expression that never matches is never due
+	cron, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := cron.Next(now); !next.IsZero() {
+		t.Errorf("expected 30th of February to never be due, actual %s", next)
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@every soon", "@fortnightly"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("expected %q to be invalid", spec)
+		}
+	}
+}
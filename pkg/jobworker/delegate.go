@@ -0,0 +1,94 @@
+package jobworker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// cgroup v2 interface files used to delegate controllers to a subtree
+const (
+	controllersFile    = "cgroup.controllers"
+	subtreeControlFile = "cgroup.subtree_control"
+)
+
+// delegatedControllers are the cgroup v2 controllers enabled for job groups in a delegated subtree
+var delegatedControllers = []string{"cpu", "cpuset", "io", "memory", "pids"}
+
+// workerGroup is the leaf group the job worker moves itself to within a delegated subtree, since cgroup v2 does not
+// allow processes in a group that has controllers enabled for it's children
+const workerGroup = "jobworker"
+
+// DelegatedCgroup returns a Cgroup rooted at the executing user's own cgroup when it has been delegated to them, i.e.
+// by systemd with `Delegate=yes`. The job worker is moved to a leaf group and the available controllers are enabled
+// for the subtree so that job groups can be created as siblings. It returns the controllers that were delegated
+func DelegatedCgroup(mountPoint string) (*Cgroup, []string, error) {
+	return delegatedCgroup(mountPoint, "/proc/self/cgroup", os.Getpid())
+}
+
+func delegatedCgroup(mountPoint, procCgroup string, pid int) (*Cgroup, []string, error) {
+	path, err := ownCgroup(procCgroup)
+	if err != nil {
+		return nil, nil, err
+	}
+	root := filepath.Join(mountPoint, path)
+	// A delegated subtree is owned by the user it's delegated to
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); !ok || int(stat.Uid) != os.Geteuid() {
+		return nil, nil, fmt.Errorf("cgroup %s is not delegated to the executing user", root)
+	}
+	// Move the job worker out of the subtree's root into a leaf group
+	leaf := filepath.Join(root, workerGroup)
+	if err = os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return nil, nil, err
+	}
+	if err = os.WriteFile(filepath.Join(leaf, procsFile), []byte(fmt.Sprintf("%d", pid)), 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to move job worker to %s: %w", leaf, err)
+	}
+	// Enable the available controllers for the job groups
+	b, err := os.ReadFile(filepath.Join(root, controllersFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	controllers := []string{}
+	enable := []string{}
+	for _, controller := range strings.Fields(string(b)) {
+		if slices.Contains(delegatedControllers, controller) {
+			controllers = append(controllers, controller)
+			enable = append(enable, "+"+controller)
+		}
+	}
+	if len(enable) > 0 {
+		if err = os.WriteFile(filepath.Join(root, subtreeControlFile), []byte(strings.Join(enable, " ")), 0644); err != nil {
+			return nil, nil, fmt.Errorf("failed to enable controllers in %s: %w", root, err)
+		}
+	}
+	return &Cgroup{root}, controllers, nil
+}
+
+// ownCgroup returns the path of a process's cgroup v2 group, relative to the cgroup mount, from a /proc/PID/cgroup file
+func ownCgroup(procCgroup string) (string, error) {
+	f, err := os.Open(procCgroup)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The unified hierarchy has ID 0 and no controllers, e.g. 0::/user.slice/user-1000.slice/jobworker.service
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no cgroup v2 group found in %s", procCgroup)
+}
@@ -0,0 +1,53 @@
+package jobworker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDelegatedCgroup(t *testing.T) {
+	// Emulate a subtree delegated by systemd to the executing user
+	tmpDir := t.TempDir()
+	path := "/user.slice/user-1000.slice/user@1000.service/app.slice/jobworker.service"
+	root := filepath.Join(tmpDir, path)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, controllersFile), []byte("cpuset cpu io memory pids rdma\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	procCgroup := filepath.Join(tmpDir, "cgroup")
+	if err := os.WriteFile(procCgroup, []byte(fmt.Sprintf("0::%s\n", path)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cgroup, controllers, err := delegatedCgroup(tmpDir, procCgroup, 1234)
+	if err != nil {
+		t.Fatalf("could not use delegated cgroup: %v", err)
+	}
+	// Assert job groups are created within the delegated subtree
+	if cgroup.rootPath != root {
+		t.Errorf("expected cgroup root to be %s, actual %s", root, cgroup.rootPath)
+	}
+	if len(controllers) != 5 {
+		t.Errorf("expected 5 delegated controllers, actual %v", controllers)
+	}
+	// Assert the job worker moved itself to a leaf group
+	procs, err := os.ReadFile(filepath.Join(root, workerGroup, procsFile))
+	if err != nil {
+		t.Fatalf("could not read job worker's cgroup.procs: %v", err)
+	}
+	if string(procs) != "1234" {
+		t.Errorf("expected job worker's PID in leaf group, actual %s", string(procs))
+	}
+	// Assert the controllers were enabled for the job groups
+	subtree, err := os.ReadFile(filepath.Join(root, subtreeControlFile))
+	if err != nil {
+		t.Fatalf("could not read cgroup.subtree_control: %v", err)
+	}
+	if string(subtree) != "+cpuset +cpu +io +memory +pids" {
+		t.Errorf("expected controllers to be enabled, actual %s", string(subtree))
+	}
+}
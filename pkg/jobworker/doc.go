@@ -34,7 +34,7 @@ func main() {
     args := []string{"-c", `"while true; do echo hello; sleep 2; done"`}
 
     // Start the job
-    job, err := jobworker.Start(JobOpts{100, 100 * jobworker.CgroupMB, 50}, cmd, args)
+    job, err := jobworker.Start(jobworker.JobOpts{CPUWeight: 100, IOWeight: 50, MemLimit: 100 * jobworker.CgroupMB}, cmd, args)
     if err != nil {
         log.Error(err)
         return
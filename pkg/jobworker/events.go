@@ -0,0 +1,184 @@
+package jobworker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRevisionUnavailable is returned when watching from a revision that is no longer in the event history, or that
+// hasn't happened yet, i.e. the job worker restarted since the watcher last saw an event
+var ErrRevisionUnavailable = errors.New("event revision is no longer available")
+
+// EventType is a change in a job's lifecycle
+type EventType string
+
+const (
+	EventCreated          EventType = "created"           // the job was submitted, it may be queued or waiting for dependencies
+	EventStarted          EventType = "started"           // the job's command was launched
+	EventRestarted        EventType = "restarted"         // the job's command was launched again according to it's RestartPolicy
+	EventOOM              EventType = "oom"               // a process in the job was killed by the OOM killer, see OOMReporter
+	EventResourcesUpdated EventType = "resources-updated" // the running job's resource controls were changed
+	EventExited           EventType = "exited"            // the job completed by itself, timed out or failed to start
+	EventStopped          EventType = "stopped"           // the job completed after being stopped, cancelled or skipped
+)
+
+// Event is a change in a job's lifecycle, with the job's status when it happened. Revisions increase by one with every
+// event published by the job worker
+type Event struct {
+	Revision uint64
+	Type     EventType
+	JobID    string
+	Owner    string
+	Time     time.Time
+	Status   JobStatus
+}
+
+// OOMReporter can optionally be implemented by a ResourceController to report how many processes in a job's group
+// have been killed by the OOM killer, each exit of the job's command that follows new kills publishes an EventOOM
+type OOMReporter interface {
+	OOMKills(name string) (int, error)
+}
+
+// eventBus keeps the most recent EVENT_HISTORY events and fans each new event out to it's watchers, safe for
+// concurrent use
+type eventBus struct {
+	sync.Mutex
+	revision uint64
+	history  []Event
+	watchers map[chan Event]func(Event) bool // each watcher's filter key'd by it's channel
+}
+
+var events = newEventBus()
+
+// newEventBus initialises an eventBus without any events or watchers
+func newEventBus() *eventBus {
+	return &eventBus{watchers: map[chan Event]func(Event) bool{}}
+}
+
+// publish records the event with the next revision and sends it to every watcher it matches. A watcher whose buffer is
+// full is closed rather than blocking the job, it can resume from the last revision it received. The recorded event is
+// returned
+func (bus *eventBus) publish(event Event) Event {
+	bus.Lock()
+	defer bus.Unlock()
+	bus.revision++
+	event.Revision = bus.revision
+	event.Time = time.Now()
+	bus.history = append(bus.history, event)
+	if len(bus.history) > EVENT_HISTORY {
+		bus.history = append([]Event{}, bus.history[len(bus.history)-EVENT_HISTORY:]...)
+	}
+	for watcher, filter := range bus.watchers {
+		if !filter(event) {
+			continue
+		}
+		select {
+		case watcher <- event:
+		default:
+			delete(bus.watchers, watcher)
+			close(watcher)
+		}
+	}
+	return event
+}
+
+// Watch returns a channel of the job events matching filter, a nil filter matches every event. Events after the
+// revision that are still in the history are sent first, so a watcher that reconnects with the last revision it
+// received doesn't miss any. To only watch new events pass the current Revision, a revision of 0 sends every event
+// since the job worker started. The channel is closed once ctx is done, or early if the watcher falls more than
+// EVENT_WATCH_BUFFER events behind.
+func Watch(ctx context.Context, revision uint64, filter func(Event) bool) (<-chan Event, error) {
+	return events.watch(ctx, revision, filter)
+}
+
+// Revision returns the revision of the last event published, watching from it only returns new events
+func Revision() uint64 {
+	return events.current()
+}
+
+// watch registers a watcher on the bus, see Watch
+func (bus *eventBus) watch(ctx context.Context, revision uint64, filter func(Event) bool) (<-chan Event, error) {
+	if filter == nil {
+		filter = func(Event) bool { return true }
+	}
+	bus.Lock()
+	if revision > bus.revision || (len(bus.history) > 0 && revision < bus.history[0].Revision-1) {
+		bus.Unlock()
+		return nil, ErrRevisionUnavailable
+	}
+	replay := []Event{}
+	for _, event := range bus.history {
+		if event.Revision > revision && filter(event) {
+			replay = append(replay, event)
+		}
+	}
+	watcher := make(chan Event, EVENT_WATCH_BUFFER+len(replay))
+	for _, event := range replay {
+		watcher <- event
+	}
+	bus.watchers[watcher] = filter
+	bus.Unlock()
+	go func() {
+		<-ctx.Done()
+		bus.Lock()
+		defer bus.Unlock()
+		if _, ok := bus.watchers[watcher]; ok {
+			delete(bus.watchers, watcher)
+			close(watcher)
+		}
+	}()
+	return watcher, nil
+}
+
+// current returns the revision of the last event published on the bus
+func (bus *eventBus) current() uint64 {
+	bus.Lock()
+	defer bus.Unlock()
+	return bus.revision
+}
+
+// publish sends an event of the type for the job with it's current status and runs the job's Hooks for it, it must not
+// be called while holding the job's or it's Queue's lock
+func (job *Job) publish(eventType EventType) {
+	status := job.Status()
+	job.runHooks(events.publish(Event{Type: eventType, JobID: job.ID, Owner: status.Owner, Status: status}))
+}
+
+// publishCreated publishes the EventCreated the first time the job is submitted, either directly with Start, to a
+// Queue or with StartAfter
+func (job *Job) publishCreated() {
+	job.announce.Do(func() {
+		job.publish(EventCreated)
+	})
+}
+
+// publishCompleted publishes an EventStopped if the job was stopped, cancelled or skipped, otherwise an EventExited
+func (job *Job) publishCompleted() {
+	if reason := job.exitReason(); reason == Stopped || reason == Skipped {
+		job.publish(EventStopped)
+		return
+	}
+	job.publish(EventExited)
+}
+
+// checkOOM publishes an EventOOM if the job's ResourceController reports processes were killed by the OOM killer
+// since it was last checked
+func (job *Job) checkOOM() {
+	reporter, ok := job.con.(OOMReporter)
+	if !ok {
+		return
+	}
+	kills, err := reporter.OOMKills(job.ID)
+	if err != nil {
+		return
+	}
+	job.Lock()
+	oom := kills > job.oomKills
+	job.oomKills = kills
+	job.Unlock()
+	if oom {
+		job.publish(EventOOM)
+	}
+}
@@ -0,0 +1,146 @@
+package jobworker
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// receiveEvents reads the job's events from the watcher until it completes
+func receiveEvents(t *testing.T, watcher <-chan Event) []EventType {
+	t.Helper()
+	types := []EventType{}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-watcher:
+			if !ok {
+				t.Fatalf("expected watcher to stay open, received %v", types)
+			}
+			types = append(types, event.Type)
+			if event.Type == EventExited || event.Type == EventStopped {
+				return types
+			}
+		case <-timeout:
+			t.Fatalf("expected job to complete, received %v", types)
+		}
+	}
+}
+
+func TestWatch_Receives_Job_Lifecycle_Events(t *testing.T) {
+	mockUserId()
+	tests := map[string]struct {
+		args     []string
+		update   bool
+		stop     bool
+		expected []EventType
+	}{
+		"exited":  {args: []string{"-c", "exit 0"}, expected: []EventType{EventCreated, EventStarted, EventExited}},
+		"stopped": {args: []string{"-c", "sleep 30"}, stop: true, expected: []EventType{EventCreated, EventStarted, EventStopped}},
+		"updated": {
+			args:     []string{"-c", "sleep 30"},
+			update:   true,
+			stop:     true,
+			expected: []EventType{EventCreated, EventStarted, EventResourcesUpdated, EventStopped},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			job := NewJobWithController(&mockController{}, JobOpts{}, cmd, tt.args...)
+			job.SetOwner("alice")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			watcher, err := Watch(ctx, Revision(), func(e Event) bool { return e.JobID == job.ID })
+			if err != nil {
+				t.Fatal("failed to watch events: ", err)
+			}
+			if err = job.Start(); err != nil {
+				t.Fatal("failed to start job: ", err)
+			}
+			if tt.update {
+				if err = job.UpdateResources(JobOpts{CPUWeight: 200}); err != nil {
+					t.Fatal("failed to update job's resources: ", err)
+				}
+			}
+			if tt.stop {
+				go job.Stop(ctx)
+			}
+			if types := receiveEvents(t, watcher); !slices.Equal(types, tt.expected) {
+				t.Errorf("expected events %v, actual %v", tt.expected, types)
+			}
+		})
+	}
+}
+
+func TestWatch_Resumes_From_Revision(t *testing.T) {
+	mockUserId()
+	revision := Revision()
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, "-c", "exit 0")
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job.Wait(ctx)
+	// Assert the events published before watching are replayed in order
+	watcher, err := Watch(ctx, revision, func(e Event) bool { return e.JobID == job.ID })
+	if err != nil {
+		t.Fatal("failed to watch events: ", err)
+	}
+	expected := []EventType{EventCreated, EventStarted, EventExited}
+	if types := receiveEvents(t, watcher); !slices.Equal(types, expected) {
+		t.Errorf("expected events %v, actual %v", expected, types)
+	}
+	// Assert revisions that aren't in the history are rejected
+	if _, err = Watch(ctx, Revision()+10, nil); err != ErrRevisionUnavailable {
+		t.Errorf("expected future revision to be unavailable, actual %v", err)
+	}
+}
+
+func TestWatch_Replays_Events_Published_After_Reading_Revision(t *testing.T) {
+	bus := newEventBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Assert an event published between reading the revision and watching from it is sent, even from revision 0
+	revision := bus.current()
+	bus.publish(Event{Type: EventCreated, JobID: "early"})
+	watcher, err := bus.watch(ctx, revision, nil)
+	if err != nil {
+		t.Fatal("failed to watch events: ", err)
+	}
+	if event := <-watcher; event.JobID != "early" || event.Revision != 1 {
+		t.Errorf("expected the early event to be replayed, actual %+v", event)
+	}
+	// Assert watching from the current revision only sends new events
+	watcher, err = bus.watch(ctx, bus.current(), nil)
+	if err != nil {
+		t.Fatal("failed to watch events: ", err)
+	}
+	select {
+	case event := <-watcher:
+		t.Errorf("expected only new events to be watched, received %+v", event)
+	default:
+	}
+}
+
+func TestWatch_Closes_Watchers_That_Fall_Behind(t *testing.T) {
+	buffer := EVENT_WATCH_BUFFER
+	EVENT_WATCH_BUFFER = 1
+	defer func() { EVENT_WATCH_BUFFER = buffer }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := newEventBus()
+	watcher, err := bus.watch(ctx, 0, func(e Event) bool { return e.JobID == "slow" })
+	if err != nil {
+		t.Fatal("failed to watch events: ", err)
+	}
+	bus.publish(Event{Type: EventCreated, JobID: "slow"})
+	bus.publish(Event{Type: EventStarted, JobID: "slow"})
+	if event := <-watcher; event.Type != EventCreated {
+		t.Errorf("expected buffered event to be received, actual %+v", event)
+	}
+	if _, ok := <-watcher; ok {
+		t.Error("expected watcher to be closed once it fell behind")
+	}
+}
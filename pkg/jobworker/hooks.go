@@ -0,0 +1,131 @@
+package jobworker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrJobSubmitted is returned when setting the hooks of a job that has already been submitted
+var ErrJobSubmitted = errors.New("job has already been submitted")
+
+// Hooks are callbacks for a job when embedding the job worker as a library, so a job can be followed without wrapping
+// it. Every hook is optional and is called asynchronously, a slow hook never blocks the job from completing or it's
+// readers from being closed, and a hook that panics is recovered without affecting the job or it's other hooks.
+// OnStart, OnResourceEvent and OnExit are called one at a time in the order they happened, OnOutputLine is called with
+// each line in order but may be called at the same time as the others
+type Hooks struct {
+	OnStart         func(JobStatus) // the job's command was launched, it's not called again if it's restarted
+	OnExit          func(JobStatus) // the job completed, after OnOutputLine has been called with every line
+	OnOutputLine    func(string)    // a line of the job's output without the trailing newline, stdout and stderr mixed
+	OnResourceEvent func(Event)     // an EventOOM, see OOMReporter, or an EventResourcesUpdated, see Job.UpdateResources
+}
+
+// SetHooks sets the callbacks for the job, returning ErrJobSubmitted once the job has been started, queued or is
+// waiting for it's dependencies. A job that's cancelled before starting only calls OnExit
+func (job *Job) SetHooks(hooks Hooks) error {
+	job.Lock()
+	defer job.Unlock()
+	if !job.pending || job.queue != nil || job.waiting {
+		return ErrJobSubmitted
+	}
+	job.hooks = &hookRunner{Hooks: hooks}
+	return nil
+}
+
+// hookRunner calls a job's hooks one at a time in a go routine that's only running while there are hooks to call
+type hookRunner struct {
+	Hooks
+	sync.Mutex
+	pending []func()
+	running bool
+	output  chan struct{} // closed once OnOutputLine has been called with every line, nil if the job's logs aren't read
+}
+
+// runHooks calls the job's hooks for the event, if it has any
+func (job *Job) runHooks(event Event) {
+	job.RLock()
+	h := job.hooks
+	job.RUnlock()
+	if h == nil {
+		return
+	}
+	switch event.Type {
+	case EventStarted:
+		if h.OnOutputLine != nil {
+			h.readOutput(job)
+		}
+		if h.OnStart != nil {
+			h.run(job.ID, "OnStart", func() { h.OnStart(event.Status) })
+		}
+	case EventOOM, EventResourcesUpdated:
+		if h.OnResourceEvent != nil {
+			h.run(job.ID, "OnResourceEvent", func() { h.OnResourceEvent(event) })
+		}
+	case EventExited, EventStopped:
+		if h.OnExit != nil {
+			h.Lock()
+			output := h.output
+			h.Unlock()
+			h.run(job.ID, "OnExit", func() {
+				if output != nil {
+					<-output
+				}
+				h.OnExit(event.Status)
+			})
+		}
+	}
+}
+
+// readOutput calls OnOutputLine with each line of the job's logs in a go routine until the job completes
+func (h *hookRunner) readOutput(job *Job) {
+	output := make(chan struct{})
+	h.Lock()
+	h.output = output
+	h.Unlock()
+	go func() {
+		defer close(output)
+		job.readLines(FollowLogs, nil, func(line []byte) error {
+			callHook(job.ID, "OnOutputLine", func() { h.OnOutputLine(string(line)) })
+			return nil
+		})
+	}()
+}
+
+// run queues the hook to be called after any hooks already queued, starting a go routine to call them if there isn't
+// one running
+func (h *hookRunner) run(jobID, name string, hook func()) {
+	h.Lock()
+	defer h.Unlock()
+	h.pending = append(h.pending, func() { callHook(jobID, name, hook) })
+	if !h.running {
+		h.running = true
+		go h.drain()
+	}
+}
+
+// drain calls the queued hooks until there are none left
+func (h *hookRunner) drain() {
+	for {
+		h.Lock()
+		if len(h.pending) == 0 {
+			h.running = false
+			h.Unlock()
+			return
+		}
+		hook := h.pending[0]
+		h.pending = h.pending[1:]
+		h.Unlock()
+		hook()
+	}
+}
+
+// callHook calls a hook, recovering if it panics so it can't crash the job worker
+func callHook(jobID, name string, hook func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("%s hook for job %s panicked: %v\n", name, jobID, r)
+		}
+	}()
+	hook()
+}
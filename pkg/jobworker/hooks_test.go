@@ -0,0 +1,177 @@
+package jobworker_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teleport-jobworker/pkg/jobworker"
+	"github.com/teleport-jobworker/pkg/jobworker/jobworkertest"
+)
+
+// hookCalls records the hooks called for a job
+type hookCalls struct {
+	sync.Mutex
+	calls  []string
+	lines  []string
+	exited chan jobworker.JobStatus
+}
+
+func (c *hookCalls) hooks() jobworker.Hooks {
+	record := func(call string) {
+		c.Lock()
+		defer c.Unlock()
+		c.calls = append(c.calls, call)
+	}
+	return jobworker.Hooks{
+		OnStart: func(jobworker.JobStatus) { record("start") },
+		OnExit: func(status jobworker.JobStatus) {
+			record("exit")
+			c.exited <- status
+		},
+		OnOutputLine: func(line string) {
+			c.Lock()
+			defer c.Unlock()
+			c.lines = append(c.lines, line)
+		},
+		OnResourceEvent: func(event jobworker.Event) { record(string(event.Type)) },
+	}
+}
+
+func TestJob_Hooks_Are_Called_For_Lifecycle_And_Output(t *testing.T) {
+	jobworkertest.RunAsExecutingUser(t)
+	c := &hookCalls{exited: make(chan jobworker.JobStatus, 1)}
+	con := jobworkertest.NewFakeController()
+	// The command waits to be released so the OOM kill can be simulated before it exits
+	release := filepath.Join(t.TempDir(), "release")
+	args := []string{"-c", fmt.Sprintf("until [ -e %s ]; do sleep 0.01; done; echo one; echo two >&2; printf three", release)}
+	job := jobworker.NewJobWithController(con, jobworker.JobOpts{}, "bash", args...)
+	job.SetHooks(c.hooks())
+	if err := job.Start(); err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	if err := con.SetFile(job.ID, "memory.events", "oom 1\noom_kill 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(release, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case status := <-c.exited:
+		if status.State != jobworker.JobExited || status.Started.IsZero() {
+			t.Errorf("expected OnExit to get the job's final status, actual %+v", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected OnExit to be called")
+	}
+	c.Lock()
+	defer c.Unlock()
+	if expected := []string{"start", "oom", "exit"}; !slices.Equal(c.calls, expected) {
+		t.Errorf("expected hooks %v to be called in order, actual %v", expected, c.calls)
+	}
+	if expected := []string{"one", "two", "three"}; !slices.Equal(c.lines, expected) {
+		t.Errorf("expected every line %q before OnExit, actual %q", expected, c.lines)
+	}
+}
+
+func TestJob_Slow_Or_Panicking_Hooks_Dont_Block_Job(t *testing.T) {
+	jobworkertest.RunAsExecutingUser(t)
+	release := make(chan struct{})
+	exited := make(chan jobworker.JobStatus, 1)
+	job := jobworker.NewJobWithController(jobworkertest.NewFakeController(), jobworker.JobOpts{}, "bash", "-c", "echo one; echo two")
+	job.SetHooks(jobworker.Hooks{
+		OnStart:      func(jobworker.JobStatus) { panic("start") },
+		OnOutputLine: func(string) { <-release },
+		OnExit:       func(status jobworker.JobStatus) { exited <- status },
+	})
+	if err := job.Start(); err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if status, err := job.Wait(ctx); err != nil || status.State != jobworker.JobExited {
+		t.Fatalf("expected job to complete while it's hooks are blocked, actual %+v, error %v", status, err)
+	}
+	// OnExit waits for every line, even though OnStart panicked
+	select {
+	case <-exited:
+		t.Fatal("expected OnExit to wait for OnOutputLine")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected OnExit to be called once OnOutputLine returned")
+	}
+}
+
+func TestJob_Cancelled_Job_Only_Calls_OnExit(t *testing.T) {
+	jobworkertest.RunAsExecutingUser(t)
+	c := &hookCalls{exited: make(chan jobworker.JobStatus, 1)}
+	con := jobworkertest.NewFakeController()
+	job := jobworker.NewJobWithController(con, jobworker.JobOpts{}, "bash", "-c", "echo never")
+	job.SetHooks(c.hooks())
+	// Wait for an upstream job that never starts
+	upstream := jobworker.NewJobWithController(con, jobworker.JobOpts{}, "bash", "-c", "exit 0")
+	if err := jobworker.StartAfter(job, []jobworker.Dependency{{Job: upstream}}, (*jobworker.Job).Start); err != nil {
+		t.Fatal("failed to submit job: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := job.Stop(ctx); err != nil {
+		t.Fatal("failed to cancel job: ", err)
+	}
+	select {
+	case status := <-c.exited:
+		if status.ExitReason != jobworker.Stopped {
+			t.Errorf("expected cancelled job to be stopped, actual %+v", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected OnExit to be called")
+	}
+	c.Lock()
+	defer c.Unlock()
+	if !slices.Equal(c.calls, []string{"exit"}) || len(c.lines) > 0 {
+		t.Errorf("expected only OnExit to be called, actual %v with lines %q", c.calls, c.lines)
+	}
+}
+
+func TestJob_OnResourceEvent_Is_Called_For_Resource_Updates(t *testing.T) {
+	jobworkertest.RunAsExecutingUser(t)
+	c := &hookCalls{exited: make(chan jobworker.JobStatus, 1)}
+	job := jobworker.NewJobWithController(jobworkertest.NewFakeController(), jobworker.JobOpts{}, "bash", "-c", "sleep 30")
+	if err := job.SetHooks(c.hooks()); err != nil {
+		t.Fatal("failed to set hooks: ", err)
+	}
+	if err := job.Start(); err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	// Assert hooks can't be changed once the job has been submitted
+	if err := job.SetHooks(jobworker.Hooks{}); err != jobworker.ErrJobSubmitted {
+		t.Errorf("expected setting hooks of a started job to return ErrJobSubmitted, actual %v", err)
+	}
+	if err := job.UpdateResources(jobworker.JobOpts{CPUWeight: 200}); err != nil {
+		t.Fatal("failed to update job's resources: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := job.Stop(ctx); err != nil {
+		t.Fatal("failed to stop job: ", err)
+	}
+	select {
+	case <-c.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected OnExit to be called")
+	}
+	c.Lock()
+	defer c.Unlock()
+	if expected := []string{"start", string(jobworker.EventResourcesUpdated), "exit"}; !slices.Equal(c.calls, expected) {
+		t.Errorf("expected hooks %v to be called in order, actual %v", expected, c.calls)
+	}
+}
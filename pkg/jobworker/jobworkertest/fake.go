@@ -0,0 +1,234 @@
+// Package jobworkertest provides utilities for testing code that uses jobworker, without manipulating /sys/fs/cgroup
+// or switching the job's user.
+package jobworkertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/teleport-jobworker/pkg/jobworker"
+)
+
+// Step is a ResourceController method that FakeController records and can be made to fail
+type Step string
+
+const (
+	CreateGroup        Step = "CreateGroup"
+	AddResourceControl Step = "AddResourceControl"
+	AddProcess         Step = "AddProcess"
+	DeleteGroup        Step = "DeleteGroup"
+	KillGroup          Step = "KillGroup"
+	SignalGroup        Step = "SignalGroup"
+)
+
+// Call records a single call to one of FakeController's ResourceController methods
+type Call struct {
+	Step  Step
+	Group string
+}
+
+// FakeController implements jobworker.ResourceController by recording the calls made to it. The JobOpts given to each
+// group are stored as the cgroup v2 interface files they would be written to, and tests can set the content of any
+// other interface file, i.e. memory.events or memory.stat, to simulate the kernel's reports
+type FakeController struct {
+	sync.Mutex
+	calls  []Call
+	groups map[string]map[string]string // interface file contents key'd by file name, key'd by group
+	opts   map[string]jobworker.JobOpts
+	fail   map[Step]error
+}
+
+// NewFakeController initialises a FakeController
+func NewFakeController() *FakeController {
+	return &FakeController{
+		groups: map[string]map[string]string{},
+		opts:   map[string]jobworker.JobOpts{},
+		fail:   map[Step]error{},
+	}
+}
+
+// FailOn makes every following call of the step return err, a nil err makes the step succeed again
+func (con *FakeController) FailOn(step Step, err error) {
+	con.Lock()
+	defer con.Unlock()
+	if err == nil {
+		delete(con.fail, step)
+		return
+	}
+	con.fail[step] = err
+}
+
+// CreateGroup records the group
+func (con *FakeController) CreateGroup(name string) error {
+	if err := con.record(CreateGroup, name); err != nil {
+		return err
+	}
+	con.Lock()
+	defer con.Unlock()
+	if _, ok := con.groups[name]; ok {
+		return fmt.Errorf("group %s: %w", name, os.ErrExist)
+	}
+	con.groups[name] = map[string]string{}
+	return nil
+}
+
+// AddResourceControl records the group's options as the content of the cgroup v2 interface files, in the same format
+// jobworker.Cgroup writes them
+func (con *FakeController) AddResourceControl(name string, opts jobworker.JobOpts) error {
+	if err := con.record(AddResourceControl, name); err != nil {
+		return err
+	}
+	con.Lock()
+	defer con.Unlock()
+	files, ok := con.groups[name]
+	if !ok {
+		return fmt.Errorf("group %s: %w", name, os.ErrNotExist)
+	}
+	con.opts[name] = opts
+	files["cpu.weight"] = fmt.Sprintf("%d", opts.CPUWeight)
+	files["io.weight"] = fmt.Sprintf("%d", opts.IOWeight)
+	files["memory.high"] = fmt.Sprintf("%d", opts.MemLimit)
+	if opts.CPUMax > 0 {
+		quota := int64(opts.CPUMax) * jobworker.CPUMaxPeriod / 1000
+		files["cpu.max"] = fmt.Sprintf("%d %d", quota, jobworker.CPUMaxPeriod)
+	}
+	if opts.CPUSet != "" {
+		files["cpuset.cpus"] = opts.CPUSet
+	}
+	return nil
+}
+
+// AddProcess records the process being added, the command runs in the executing process's own cgroup
+func (con *FakeController) AddProcess(name string, cmd *exec.Cmd) error {
+	if err := con.record(AddProcess, name); err != nil {
+		return err
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{CgroupFD: -1}
+	return nil
+}
+
+// DeleteGroup forgets the group and it's options
+func (con *FakeController) DeleteGroup(name string) error {
+	if err := con.record(DeleteGroup, name); err != nil {
+		return err
+	}
+	con.Lock()
+	defer con.Unlock()
+	delete(con.groups, name)
+	delete(con.opts, name)
+	return nil
+}
+
+// KillGroup records the group being killed, the job's process group is signalled by jobworker.Job.Stop
+func (con *FakeController) KillGroup(name string) error {
+	return con.record(KillGroup, name)
+}
+
+// SignalGroup records the group being signalled, since the fake's processes are not in the group none are signalled
+func (con *FakeController) SignalGroup(name string, sig syscall.Signal) error {
+	return con.record(SignalGroup, name)
+}
+
+// OOMKills returns the oom_kill count from the group's memory.events file, set with SetFile, or 0 if it hasn't been set
+func (con *FakeController) OOMKills(name string) (int, error) {
+	events, err := con.ReadFile(name, "memory.events")
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return jobworker.ParseOOMKills(events)
+}
+
+// Opts returns the JobOpts added to a group, false if the group has no resource controls
+func (con *FakeController) Opts(name string) (jobworker.JobOpts, bool) {
+	con.Lock()
+	defer con.Unlock()
+	opts, ok := con.opts[name]
+	return opts, ok
+}
+
+// Groups returns the names of the groups that have been created and not deleted
+func (con *FakeController) Groups() []string {
+	con.Lock()
+	defer con.Unlock()
+	names := []string{}
+	for name := range con.groups {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Calls returns the calls made to the controller in order
+func (con *FakeController) Calls() []Call {
+	con.Lock()
+	defer con.Unlock()
+	return append([]Call{}, con.calls...)
+}
+
+// SetFile sets the content of one of a group's interface files, i.e. "memory.events" to "oom 0\noom_kill 1\n"
+func (con *FakeController) SetFile(name, file, content string) error {
+	con.Lock()
+	defer con.Unlock()
+	files, ok := con.groups[name]
+	if !ok {
+		return fmt.Errorf("group %s: %w", name, os.ErrNotExist)
+	}
+	files[file] = content
+	return nil
+}
+
+// ReadFile returns the content of one of a group's interface files, as either written by AddResourceControl or SetFile
+func (con *FakeController) ReadFile(name, file string) (string, error) {
+	con.Lock()
+	defer con.Unlock()
+	content, ok := con.groups[name][file]
+	if !ok {
+		return "", fmt.Errorf("%s/%s: %w", name, file, os.ErrNotExist)
+	}
+	return content, nil
+}
+
+// record appends the call and returns the error injected for the step, if any
+func (con *FakeController) record(step Step, name string) error {
+	con.Lock()
+	defer con.Unlock()
+	con.calls = append(con.calls, Call{Step: step, Group: name})
+	return con.fail[step]
+}
+
+// RunAsExecutingUser disables switching the job's user for the duration of the test, restoring WORKER_UID and
+// WORKER_GID once it completes
+func RunAsExecutingUser(t testing.TB) {
+	t.Helper()
+	uid, gid := jobworker.WORKER_UID, jobworker.WORKER_GID
+	jobworker.WORKER_UID, jobworker.WORKER_GID = -1, -1
+	t.Cleanup(func() {
+		jobworker.WORKER_UID, jobworker.WORKER_GID = uid, gid
+	})
+}
+
+// StartJob starts a job as the executing user with a new FakeController, failing the test if the job can't be started.
+// The job is stopped and deleted once the test completes, removing it's log file
+func StartJob(t testing.TB, opts jobworker.JobOpts, cmd string, args ...string) (*jobworker.Job, *FakeController) {
+	t.Helper()
+	RunAsExecutingUser(t)
+	con := NewFakeController()
+	job, err := jobworker.StartWithController(con, opts, cmd, args...)
+	if err != nil {
+		t.Fatalf("failed to start job: %v", err)
+	}
+	t.Cleanup(func() {
+		job.Stop(context.Background())
+		if err := job.Delete(); err != nil {
+			t.Errorf("failed to delete job: %v", err)
+		}
+	})
+	return job, con
+}
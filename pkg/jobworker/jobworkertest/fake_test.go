@@ -0,0 +1,104 @@
+package jobworkertest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/teleport-jobworker/pkg/jobworker"
+)
+
+func TestStartJob_Records_Resource_Controls(t *testing.T) {
+	opts := jobworker.JobOpts{CPUWeight: 50, IOWeight: 100, MemLimit: 10 * jobworker.CgroupMB, CPUMax: 500}
+	job, con := StartJob(t, opts, "sleep", "10")
+
+	// Assert the job's group was created with it's options
+	actual, ok := con.Opts(job.ID)
+	if !ok || actual != opts {
+		t.Errorf("expected opts %v for job's group, actual %v", opts, actual)
+	}
+	if cpuMax, _ := con.ReadFile(job.ID, "cpu.max"); cpuMax != "50000 100000" {
+		t.Errorf("expected cpu.max to be 50000 100000, actual %s", cpuMax)
+	}
+	if ioWeight, _ := con.ReadFile(job.ID, "io.weight"); ioWeight != "100" {
+		t.Errorf("expected io.weight to be 100, actual %s", ioWeight)
+	}
+	if memHigh, _ := con.ReadFile(job.ID, "memory.high"); memHigh != "10485760" {
+		t.Errorf("expected memory.high to be 10485760, actual %s", memHigh)
+	}
+	// Assert simulated interface files can be read back
+	if err := con.SetFile(job.ID, "memory.events", "oom 0\noom_kill 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := con.ReadFile(job.ID, "memory.events"); events != "oom 0\noom_kill 1\n" {
+		t.Errorf("expected simulated memory.events, actual %s", events)
+	}
+	// Assert stopping the job kills and deletes it's group
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := job.Stop(ctx); err != nil {
+		t.Fatalf("failed to stop job: %v", err)
+	}
+	if len(con.Groups()) != 0 {
+		t.Errorf("expected job's group to be deleted, actual groups %v", con.Groups())
+	}
+	// The group is killed once the command exits, and again by Stop before it's deleted
+	expected := []Step{CreateGroup, AddResourceControl, AddProcess, KillGroup, KillGroup, DeleteGroup}
+	steps := []Step{}
+	for _, call := range con.Calls() {
+		steps = append(steps, call.Step)
+	}
+	if !slices.Equal(steps, expected) {
+		t.Errorf("expected calls %v, actual %v", expected, steps)
+	}
+}
+
+func TestFakeController_FailOn(t *testing.T) {
+	RunAsExecutingUser(t)
+	injected := errors.New("injected failure")
+	for _, step := range []Step{CreateGroup, AddResourceControl, AddProcess} {
+		con := NewFakeController()
+		con.FailOn(step, injected)
+		if _, err := jobworker.StartWithController(con, jobworker.JobOpts{}, "true"); !errors.Is(err, injected) {
+			t.Errorf("expected start to fail on %s with injected error, actual %v", step, err)
+		}
+	}
+	// Assert the failure can be cleared
+	con := NewFakeController()
+	con.FailOn(DeleteGroup, injected)
+	con.CreateGroup("test")
+	if err := con.DeleteGroup("test"); !errors.Is(err, injected) {
+		t.Errorf("expected delete to fail with injected error, actual %v", err)
+	}
+	con.FailOn(DeleteGroup, nil)
+	if err := con.DeleteGroup("test"); err != nil {
+		t.Errorf("expected delete to succeed once failure cleared, actual %v", err)
+	}
+}
+
+func TestFakeController_Reports_OOM_Kills(t *testing.T) {
+	job, con := StartJob(t, jobworker.JobOpts{}, "sleep", "0.5")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watcher, err := jobworker.Watch(ctx, jobworker.Revision(), func(e jobworker.Event) bool { return e.JobID == job.ID })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulate the kernel killing a process in the job's group
+	if err = con.SetFile(job.ID, "memory.events", "oom 1\noom_kill 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	types := []jobworker.EventType{}
+	for event := range watcher {
+		types = append(types, event.Type)
+		if event.Type == jobworker.EventExited {
+			break
+		}
+	}
+	expected := []jobworker.EventType{jobworker.EventOOM, jobworker.EventExited}
+	if !slices.Equal(types, expected) {
+		t.Errorf("expected events %v, actual %v", expected, types)
+	}
+}
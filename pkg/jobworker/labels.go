@@ -0,0 +1,196 @@
+package jobworker
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// labelName matches the name of a label key and a label value, alphanumerics with dashes, underscores and dots
+// between them
+var labelName = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+
+// labelPrefix matches the optional DNS subdomain prefix of a label key, e.g. example.com in example.com/team
+var labelPrefix = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// Metadata is attached to a job when it's created. The optional name is an easier way to refer to the job than it's
+// ID, labels identify the job so that it can be selected, e.g. team=infra, while annotations hold any other
+// information about the job that isn't used to select it
+type Metadata struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// Validate checks the name, the keys of the labels and annotations, the length of the label values, and that there are
+// no more than MAX_LABELS labels and MAX_ANNOTATIONS_SIZE bytes of annotations
+func (m Metadata) Validate() error {
+	if err := validateName(m.Name); err != nil {
+		return err
+	}
+	if len(m.Labels) > MAX_LABELS {
+		return fmt.Errorf("job has %d labels, the maximum is %d", len(m.Labels), MAX_LABELS)
+	}
+	for key, value := range m.Labels {
+		if err := validateKey(key); err != nil {
+			return err
+		}
+		if err := validateValue(value); err != nil {
+			return err
+		}
+	}
+	size := 0
+	for key, value := range m.Annotations {
+		if err := validateKey(key); err != nil {
+			return err
+		}
+		size += len(key) + len(value)
+	}
+	if size > MAX_ANNOTATIONS_SIZE {
+		return fmt.Errorf("job has %d bytes of annotations, the maximum is %d", size, MAX_ANNOTATIONS_SIZE)
+	}
+	return nil
+}
+
+// validateKey checks a label or annotation key is a name of up to 63 characters, optionally prefixed by a DNS
+// subdomain of up to 253 characters and a slash
+func validateKey(key string) error {
+	prefix, name, ok := strings.Cut(key, "/")
+	if !ok {
+		prefix, name = "", key
+	} else if len(prefix) > 253 || !labelPrefix.MatchString(prefix) {
+		return fmt.Errorf("key %q prefix must be a DNS subdomain", key)
+	}
+	if len(name) > 63 || !labelName.MatchString(name) {
+		return fmt.Errorf("key %q must be up to 63 alphanumerics, with dashes, underscores or dots between them", key)
+	}
+	return nil
+}
+
+// validateName checks a job name is empty or up to 63 characters like a key's name, and isn't a UUID so it can't be
+// confused with a job's ID
+func validateName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if len(name) > 63 || !labelName.MatchString(name) {
+		return fmt.Errorf("name %q must be up to 63 alphanumerics, with dashes, underscores or dots between them", name)
+	}
+	if _, err := uuid.Parse(name); err == nil {
+		return fmt.Errorf("name %q must not be a UUID", name)
+	}
+	return nil
+}
+
+// validateValue checks a label value is empty or up to 63 characters like a key's name
+func validateValue(value string) error {
+	if value != "" && (len(value) > 63 || !labelName.MatchString(value)) {
+		return fmt.Errorf("label value %q must be up to 63 alphanumerics, with dashes, underscores or dots between them", value)
+	}
+	return nil
+}
+
+// SetMetadata attaches a name, labels and annotations to the job, it must be called before the job is submitted so
+// that every event for the job includes them
+func (job *Job) SetMetadata(metadata Metadata) {
+	job.Lock()
+	defer job.Unlock()
+	job.metadata = Metadata{Name: metadata.Name, Labels: maps.Clone(metadata.Labels), Annotations: maps.Clone(metadata.Annotations)}
+}
+
+// SelectorOperator is how a Requirement compares a label
+type SelectorOperator string
+
+const (
+	SelectEquals       SelectorOperator = "="  // the label has the value
+	SelectNotEquals    SelectorOperator = "!=" // the label does not have the value, including when it isn't set
+	SelectExists       SelectorOperator = "exists"
+	SelectDoesNotExist SelectorOperator = "!"
+)
+
+// Requirement is a single condition of a Selector on a label
+type Requirement struct {
+	Key      string
+	Operator SelectorOperator
+	Value    string
+}
+
+// Selector selects jobs by their labels, a job must satisfy every requirement. An empty Selector selects every job
+type Selector []Requirement
+
+// ParseSelector parses comma separated requirements in the form key=value (or key==value), key!=value, key for the
+// label to exist or !key for it not to, e.g. team=infra,env!=prod
+func ParseSelector(selector string) (Selector, error) {
+	s := Selector{}
+	if strings.TrimSpace(selector) == "" {
+		return s, nil
+	}
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		var r Requirement
+		if key, value, ok := strings.Cut(term, "!="); ok {
+			r = Requirement{Key: key, Operator: SelectNotEquals, Value: value}
+		} else if key, value, ok := strings.Cut(term, "=="); ok {
+			r = Requirement{Key: key, Operator: SelectEquals, Value: value}
+		} else if key, value, ok := strings.Cut(term, "="); ok {
+			r = Requirement{Key: key, Operator: SelectEquals, Value: value}
+		} else if key, ok := strings.CutPrefix(term, "!"); ok {
+			r = Requirement{Key: key, Operator: SelectDoesNotExist}
+		} else {
+			r = Requirement{Key: term, Operator: SelectExists}
+		}
+		r.Key, r.Value = strings.TrimSpace(r.Key), strings.TrimSpace(r.Value)
+		if err := validateKey(r.Key); err != nil {
+			return nil, fmt.Errorf("selector %q not valid: %w", selector, err)
+		}
+		if err := validateValue(r.Value); err != nil {
+			return nil, fmt.Errorf("selector %q not valid: %w", selector, err)
+		}
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+// Matches returns true if the labels satisfy every requirement of the selector
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		value, ok := labels[r.Key]
+		switch r.Operator {
+		case SelectEquals:
+			if !ok || value != r.Value {
+				return false
+			}
+		case SelectNotEquals:
+			if ok && value == r.Value {
+				return false
+			}
+		case SelectExists:
+			if !ok {
+				return false
+			}
+		case SelectDoesNotExist:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	terms := []string{}
+	for _, r := range s {
+		switch r.Operator {
+		case SelectExists:
+			terms = append(terms, r.Key)
+		case SelectDoesNotExist:
+			terms = append(terms, "!"+r.Key)
+		default:
+			terms = append(terms, r.Key+string(r.Operator)+r.Value)
+		}
+	}
+	return strings.Join(terms, ",")
+}
@@ -0,0 +1,77 @@
+package jobworker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMetadata_Validate(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i <= MAX_LABELS; i++ {
+		tooMany[fmt.Sprintf("label%d", i)] = "x"
+	}
+	tests := map[string]struct {
+		metadata Metadata
+		valid    bool
+	}{
+		"empty":            {metadata: Metadata{}, valid: true},
+		"name":             {metadata: Metadata{Name: "nightly-backup.2"}, valid: true},
+		"invalid name":     {metadata: Metadata{Name: "nightly backup"}},
+		"uuid name":        {metadata: Metadata{Name: "9b2f3a36-8c1d-4a4e-9f3b-2d1c0e6f7a58"}},
+		"labels":           {metadata: Metadata{Labels: map[string]string{"team": "infra", "example.com/pipeline": "nightly", "empty": ""}}, valid: true},
+		"annotations":      {metadata: Metadata{Annotations: map[string]string{"description": "Nightly backup, see the runbook!"}}, valid: true},
+		"key":              {metadata: Metadata{Labels: map[string]string{"-team": "infra"}}},
+		"prefix":           {metadata: Metadata{Labels: map[string]string{"Example.com/team": "infra"}}},
+		"long key":         {metadata: Metadata{Labels: map[string]string{strings.Repeat("a", 64): "infra"}}},
+		"value":            {metadata: Metadata{Labels: map[string]string{"team": "infra ops"}}},
+		"long value":       {metadata: Metadata{Labels: map[string]string{"team": strings.Repeat("a", 64)}}},
+		"too many":         {metadata: Metadata{Labels: tooMany}},
+		"annotation key":   {metadata: Metadata{Annotations: map[string]string{"a b": "c"}}},
+		"annotations size": {metadata: Metadata{Annotations: map[string]string{"description": strings.Repeat("a", MAX_ANNOTATIONS_SIZE)}}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := tt.metadata.Validate(); (err == nil) != tt.valid {
+				t.Errorf("expected valid to be %t, error %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestSelector_Matches_Labels(t *testing.T) {
+	labels := map[string]string{"team": "infra", "env": "staging", "example.com/pipeline": "nightly"}
+	tests := map[string]bool{
+		"":                                     true,
+		"team=infra":                           true,
+		"team==infra":                          true,
+		"team=infra,env!=prod":                 true,
+		" team = infra , example.com/pipeline": true,
+		"!owner":                               true,
+		"owner!=alice":                         true,
+		"team=web":                             false,
+		"team=infra,env=prod":                  false,
+		"env!=staging":                         false,
+		"owner":                                false,
+		"!team":                                false,
+	}
+	for selector, expected := range tests {
+		t.Run(selector, func(t *testing.T) {
+			s, err := ParseSelector(selector)
+			if err != nil {
+				t.Fatal("failed to parse selector: ", err)
+			}
+			if s.Matches(labels) != expected {
+				t.Errorf("expected %q matches to be %t", s, expected)
+			}
+		})
+	}
+}
+
+func TestParseSelector_Rejects_Invalid_Selectors(t *testing.T) {
+	for _, selector := range []string{"team=infra,", "=infra", "team=in fra", "!", "team!=a=b"} {
+		if _, err := ParseSelector(selector); err == nil {
+			t.Errorf("expected selector %q to be rejected", selector)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package jobworker
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// LeakKind is the type of resource that failed to be cleaned up after a job
+type LeakKind string
+
+const (
+	LeakedCgroup  LeakKind = "cgroup"
+	LeakedLogFile LeakKind = "log"
+)
+
+// Leak records a job's resource that could not be removed so that an operator can act on it
+type Leak struct {
+	Kind     LeakKind
+	JobID    string
+	Resource string // cgroup name or log file path
+	Err      string
+	Time     time.Time
+}
+
+// leakRegistry is an in memory list of leaked resources, safe for concurrent use
+// TODO in production this would be persisted so leaks are not forgotten if the job worker restarts
+type leakRegistry struct {
+	sync.RWMutex
+	leaks []Leak
+}
+
+var leaks = &leakRegistry{leaks: []Leak{}}
+
+// recordLeak adds a resource to the leak registry, replacing the resource's previous leak if it failed to be removed
+// before. Only the MAX_LEAKS most recent leaks are kept
+func recordLeak(kind LeakKind, id, resource string, err error) {
+	leaks.Lock()
+	defer leaks.Unlock()
+	leaks.remove(kind, resource)
+	leaks.leaks = append(leaks.leaks, Leak{Kind: kind, JobID: id, Resource: resource, Err: err.Error(), Time: time.Now()})
+	if len(leaks.leaks) > MAX_LEAKS {
+		leaks.leaks = slices.Delete(leaks.leaks, 0, len(leaks.leaks)-MAX_LEAKS)
+	}
+}
+
+// clearLeak removes a resource from the leak registry once a retry has removed it
+func clearLeak(kind LeakKind, resource string) {
+	leaks.Lock()
+	defer leaks.Unlock()
+	leaks.remove(kind, resource)
+}
+
+// Leaks returns a copy of all the cgroups and log files that could not be removed when stopping jobs
+func Leaks() []Leak {
+	leaks.RLock()
+	defer leaks.RUnlock()
+	return append([]Leak{}, leaks.leaks...)
+}
+
+// remove deletes the resource's leak, the caller must hold the lock
+func (r *leakRegistry) remove(kind LeakKind, resource string) {
+	r.leaks = slices.DeleteFunc(r.leaks, func(leak Leak) bool { return leak.Kind == kind && leak.Resource == resource })
+}
@@ -0,0 +1,90 @@
+package jobworker_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/teleport-jobworker/pkg/jobworker"
+	"github.com/teleport-jobworker/pkg/jobworker/jobworkertest"
+)
+
+// leaked returns true if the job's cgroup is in the leak registry
+func leaked(jobID string) bool {
+	return slices.ContainsFunc(jobworker.Leaks(), func(leak jobworker.Leak) bool {
+		return leak.JobID == jobID && leak.Kind == jobworker.LeakedCgroup
+	})
+}
+
+// kills counts the calls to KillGroup for the group
+func kills(con *jobworkertest.FakeController, name string) int {
+	n := 0
+	for _, call := range con.Calls() {
+		if call.Step == jobworkertest.KillGroup && call.Group == name {
+			n++
+		}
+	}
+	return n
+}
+
+func TestJobWorker_Stop_Records_Leaked_Cgroup(t *testing.T) {
+	args := []string{"-c", "while true; do sleep 2; done"}
+	opts := jobworker.JobOpts{CPUWeight: 100, IOWeight: 100, MemLimit: 50 * jobworker.CgroupMB}
+
+	// Run the job with a cgroup that can't be removed
+	job, con := jobworkertest.StartJob(t, opts, "bash", args...)
+	con.FailOn(jobworkertest.DeleteGroup, jobworker.ErrCgroupPopulated)
+	t.Cleanup(func() { con.FailOn(jobworkertest.DeleteGroup, nil) })
+
+	// Stop the job and assert the cgroup that failed to delete was recorded
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := job.Stop(ctx); err != nil {
+		t.Errorf("expected to be able to stop the job, error : %v", err)
+	}
+	if !leaked(job.ID) {
+		t.Error("expected job's cgroup to be recorded as a leak")
+	}
+}
+
+func TestJobWorker_Delete_Clears_Leak_Once_Cgroup_Is_Removed(t *testing.T) {
+	job, con := jobworkertest.StartJob(t, jobworker.JobOpts{}, "bash", "-c", "sleep 10")
+	con.FailOn(jobworkertest.DeleteGroup, jobworker.ErrCgroupPopulated)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := job.Stop(ctx); err != nil {
+		t.Errorf("expected to be able to stop the job, error : %v", err)
+	}
+	if !leaked(job.ID) {
+		t.Fatal("expected job's cgroup to be recorded as a leak")
+	}
+	// Deleting the job retries removing it's cgroup, which succeeds
+	con.FailOn(jobworkertest.DeleteGroup, nil)
+	if err := job.Delete(); err != nil {
+		t.Fatal("failed to delete job: ", err)
+	}
+	if leaked(job.ID) {
+		t.Error("expected job's leak to be cleared once it's cgroup was removed")
+	}
+}
+
+func TestJobWorker_Kills_Group_After_Command_Exits(t *testing.T) {
+	job, con := jobworkertest.StartJob(t, jobworker.JobOpts{}, "bash", "-c", "exit 0")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := job.Wait(ctx); err != nil {
+		t.Fatal("expected job to complete: ", err)
+	}
+	// Assert anything the command left behind is killed once it exits by itself
+	if n := kills(con, job.ID); n != 1 {
+		t.Errorf("expected job's group to be killed once it exited, actual %d kills", n)
+	}
+	// Assert stopping a job whose process group has exited isn't an error, and still kills the group
+	if err := job.Stop(ctx); err != nil {
+		t.Errorf("expected to be able to stop a job that exited, error: %v", err)
+	}
+	if kills(con, job.ID) < 2 {
+		t.Error("expected job's group to be killed when stopped after it exited")
+	}
+}
@@ -0,0 +1,181 @@
+package jobworker
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// QueueOrder determines which waiting job a Queue starts when a slot frees
+type QueueOrder string
+
+const (
+	FIFOOrder     QueueOrder = "fifo"     // the job that has been waiting longest, the default
+	PriorityOrder QueueOrder = "priority" // the job with the highest JobOpts.Priority, then the longest waiting
+)
+
+// queued is a job waiting in a Queue
+type queued struct {
+	job   *Job
+	owner string
+	seq   uint64
+}
+
+// Queue limits the number of jobs running at once, both in total and per owner. Jobs submitted while the limits are
+// reached wait in the queue and are started automatically as running jobs complete.
+type Queue struct {
+	sync.Mutex
+	maxRunning  int // 0 for no limit
+	maxPerOwner int // 0 for no limit
+	order       QueueOrder
+	start       func(*Job) error
+	waiting     []*queued
+	running     map[string]int // number of running jobs key'd by owner
+	total       int
+	seq         uint64
+}
+
+// NewQueue initialises a Queue with the limits and order, jobs are started using start, i.e. Admission.StartJob
+func NewQueue(maxRunning, maxPerOwner int, order QueueOrder, start func(*Job) error) (*Queue, error) {
+	switch order {
+	case "":
+		order = FIFOOrder
+	case FIFOOrder, PriorityOrder:
+	default:
+		return nil, fmt.Errorf("queue order %q not valid", order)
+	}
+	return &Queue{
+		maxRunning:  maxRunning,
+		maxPerOwner: maxPerOwner,
+		order:       order,
+		start:       start,
+		waiting:     []*queued{},
+		running:     map[string]int{},
+	}, nil
+}
+
+// Submit starts an owner's pending job if there is a free slot, returning any error starting it. Otherwise the job
+// waits in the queue, reporting it's position in it's status, until it's started or cancelled by Job.Stop
+func (q *Queue) Submit(owner string, job *Job) error {
+	job.publishCreated()
+	q.Lock()
+	if !q.hasSlot(owner) {
+		job.Lock()
+		job.queue = q
+		job.Unlock()
+		q.seq++
+		q.waiting = append(q.waiting, &queued{job: job, owner: owner, seq: q.seq})
+		q.sort()
+		q.Unlock()
+		return nil
+	}
+	q.take(owner)
+	q.Unlock()
+	return q.startJob(owner, job)
+}
+
+// hasSlot returns true if another of the owner's jobs can run, the caller must hold the lock
+func (q *Queue) hasSlot(owner string) bool {
+	return (q.maxRunning <= 0 || q.total < q.maxRunning) && (q.maxPerOwner <= 0 || q.running[owner] < q.maxPerOwner)
+}
+
+// take occupies one of the owner's slots, the caller must hold the lock
+func (q *Queue) take(owner string) {
+	q.total++
+	q.running[owner]++
+}
+
+// release frees one of the owner's slots and starts the waiting jobs that now have one
+func (q *Queue) release(owner string) {
+	q.Lock()
+	q.total--
+	q.running[owner]--
+	next := q.dispatch()
+	q.Unlock()
+	q.startQueued(next)
+}
+
+// startJob starts a job in a slot taken for it and holds the slot until the job completes. The queue's lock must not
+// be held, since starting a job publishes it's events. If the job fails to start the slot is given back
+func (q *Queue) startJob(owner string, job *Job) error {
+	if err := q.start(job); err != nil {
+		q.release(owner)
+		return err
+	}
+	go func() {
+		<-job.done
+		q.release(owner)
+	}()
+	return nil
+}
+
+// startQueued starts jobs taken from the queue by dispatch, a job that fails to start completes with the exit reason
+// StartFailed
+func (q *Queue) startQueued(jobs []*queued) {
+	for _, next := range jobs {
+		if err := q.startJob(next.owner, next.job); err != nil {
+			fmt.Printf("failed to start queued job %s: %v\n", next.job.ID, err)
+			next.job.completePending(StartFailed)
+		}
+	}
+}
+
+// dispatch removes waiting jobs from the queue in order while there are free slots, skipping jobs whose owner is at
+// their limit, and takes a slot for each. The caller must hold the lock and start the jobs with startQueued once it's
+// released
+func (q *Queue) dispatch() []*queued {
+	var jobs []*queued
+	for i := 0; i < len(q.waiting); {
+		if q.maxRunning > 0 && q.total >= q.maxRunning {
+			break
+		}
+		next := q.waiting[i]
+		if !q.hasSlot(next.owner) {
+			i++
+			continue
+		}
+		q.waiting = slices.Delete(q.waiting, i, i+1)
+		next.job.Lock()
+		next.job.queue = nil
+		next.job.Unlock()
+		q.take(next.owner)
+		jobs = append(jobs, next)
+	}
+	return jobs
+}
+
+// sort orders the waiting jobs by the queue's order, the caller must hold the lock
+func (q *Queue) sort() {
+	slices.SortStableFunc(q.waiting, func(a, b *queued) int {
+		if q.order == PriorityOrder && a.job.opts.Priority != b.job.opts.Priority {
+			return cmp.Compare(b.job.opts.Priority, a.job.opts.Priority)
+		}
+		return cmp.Compare(a.seq, b.seq)
+	})
+}
+
+// position returns a waiting job's 1 based position in the queue, 0 if it's not waiting
+func (q *Queue) position(job *Job) int {
+	q.Lock()
+	defer q.Unlock()
+	for i, w := range q.waiting {
+		if w.job == job {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// remove removes a waiting job from the queue, returning false if it's not waiting
+func (q *Queue) remove(job *Job) bool {
+	q.Lock()
+	defer q.Unlock()
+	for i, w := range q.waiting {
+		if w.job == job {
+			q.waiting = slices.Delete(q.waiting, i, i+1)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package jobworker
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// groupsController records the groups created to assert cancelled jobs never create a cgroup
+type groupsController struct {
+	mockController
+	sync.Mutex
+	groups []string
+}
+
+func (con *groupsController) CreateGroup(name string) error {
+	con.Lock()
+	defer con.Unlock()
+	con.groups = append(con.groups, name)
+	return nil
+}
+
+func (con *groupsController) created(name string) bool {
+	con.Lock()
+	defer con.Unlock()
+	return slices.Contains(con.groups, name)
+}
+
+func startJob(job *Job) error {
+	return job.Start()
+}
+
+func TestQueue_Starts_Queued_Jobs_When_Slot_Frees(t *testing.T) {
+	mockUserId()
+	con := &groupsController{}
+	q, err := NewQueue(1, 0, FIFOOrder, startJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := []*Job{}
+	for i := 0; i < 3; i++ {
+		job := NewJobWithController(con, JobOpts{}, cmd, "-c", "sleep 30")
+		if err = q.Submit("alice", job); err != nil {
+			t.Fatal("failed to submit job: ", err)
+		}
+		jobs = append(jobs, job)
+	}
+	// Assert only the first job is running and the others report their position
+	if status := jobs[0].Status(); !status.Running || status.Queued {
+		t.Errorf("expected first job to be running, actual %+v", status)
+	}
+	for i, job := range jobs[1:] {
+		if status := job.Status(); !status.Queued || status.QueuePosition != int32(i+1) {
+			t.Errorf("expected job %d to be queued at position %d, actual %+v", i+1, i+1, status)
+		}
+	}
+	// Assert stopping a queued job cancels it without creating a cgroup
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = jobs[2].Stop(ctx); err != nil {
+		t.Errorf("expected to be able to stop queued job, error: %v", err)
+	}
+	if status := jobs[2].Status(); status.Queued || status.ExitReason != Stopped || con.created(jobs[2].ID) {
+		t.Errorf("expected queued job to be cancelled without a cgroup, actual %+v", status)
+	}
+	// Assert stopping the running job starts the next queued job
+	if err = jobs[0].Stop(ctx); err != nil {
+		t.Errorf("expected to be able to stop the job, error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if status := jobs[1].Status(); !status.Running {
+		t.Errorf("expected queued job to start once a slot freed, actual %+v", status)
+	}
+	jobs[1].Stop(ctx)
+}
+
+func TestQueue_Per_Owner_Limit_And_Priority(t *testing.T) {
+	mockUserId()
+	con := &groupsController{}
+	q, err := NewQueue(2, 1, PriorityOrder, startJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	submit := func(owner string, priority int32) *Job {
+		job := NewJobWithController(con, JobOpts{Priority: priority}, cmd, "-c", "sleep 30")
+		if err := q.Submit(owner, job); err != nil {
+			t.Fatal("failed to submit job: ", err)
+		}
+		t.Cleanup(func() { job.Stop(ctx) })
+		return job
+	}
+	alice := submit("alice", 0)
+	low := submit("alice", 1)
+	high := submit("alice", 5)
+	// Assert another owner can still run while alice is at her limit
+	bob := submit("bob", 0)
+	if !alice.Status().Running || !bob.Status().Running {
+		t.Error("expected each owner to run a job")
+	}
+	if high.Status().QueuePosition != 1 || low.Status().QueuePosition != 2 {
+		t.Errorf("expected higher priority job to be first in the queue, actual %+v %+v", high.Status(), low.Status())
+	}
+	// Assert the higher priority job starts when alice's slot frees
+	if err = alice.Stop(ctx); err != nil {
+		t.Errorf("expected to be able to stop the job, error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !high.Status().Running || !low.Status().Queued {
+		t.Errorf("expected higher priority job to start first, actual %+v %+v", high.Status(), low.Status())
+	}
+}
+
+func TestQueue_Gives_Slot_Back_When_Job_Fails_To_Start(t *testing.T) {
+	mockUserId()
+	failing := NewJobWithController(&mockController{}, JobOpts{}, cmd, "-c", "sleep 30")
+	q, err := NewQueue(1, 0, FIFOOrder, func(job *Job) error {
+		if job == failing {
+			return ErrCgroupPopulated
+		}
+		return job.Start()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = q.Submit("alice", failing); err == nil {
+		t.Fatal("expected submitting the job to fail")
+	}
+	// Assert the failed job's slot is free for the next job
+	job := NewJobWithController(&mockController{}, JobOpts{}, cmd, "-c", "sleep 30")
+	if err = q.Submit("alice", job); err != nil {
+		t.Fatal("failed to submit job: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer job.Stop(ctx)
+	if status := job.Status(); !status.Running || status.Queued {
+		t.Errorf("expected job to run in the freed slot, actual %+v", status)
+	}
+}
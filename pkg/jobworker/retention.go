@@ -0,0 +1,77 @@
+package jobworker
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// ErrJobNotCompleted is returned when deleting a job that is still pending or running
+var ErrJobNotCompleted = errors.New("job has not completed")
+
+// Expired returns an owner's completed jobs that are outside the retention policy, i.e. all but the keep most recently
+// finished, and any that finished more than ttl before now. A keep or ttl of 0 doesn't limit the jobs retained
+func Expired(jobs []*Job, keep int, ttl time.Duration, now time.Time) []*Job {
+	return expired(jobs, func(job *Job) (time.Time, bool) {
+		return completedAt([]*Job{job})
+	}, keep, ttl, now)
+}
+
+// ExpiredWorkflows returns an owner's completed workflows that are outside the retention policy, like Expired where a
+// workflow finished when it's last job did
+func ExpiredWorkflows(workflows []*Workflow, keep int, ttl time.Duration, now time.Time) []*Workflow {
+	return expired(workflows, func(w *Workflow) (time.Time, bool) {
+		jobs := []*Job{}
+		for _, job := range w.jobs {
+			jobs = append(jobs, job)
+		}
+		return completedAt(jobs)
+	}, keep, ttl, now)
+}
+
+// ExpiredArrays returns an owner's completed arrays that are outside the retention policy, like Expired where an array
+// finished when it's last job did
+func ExpiredArrays(arrays []*Array, keep int, ttl time.Duration, now time.Time) []*Array {
+	return expired(arrays, func(a *Array) (time.Time, bool) {
+		return completedAt(a.jobs)
+	}, keep, ttl, now)
+}
+
+// expired returns the items that have completed and are outside the retention policy, most recently finished first
+func expired[T comparable](items []T, completed func(T) (time.Time, bool), keep int, ttl time.Duration, now time.Time) []T {
+	done := []T{}
+	finished := map[T]time.Time{}
+	for _, item := range items {
+		at, ok := completed(item)
+		if !ok {
+			continue
+		}
+		done = append(done, item)
+		finished[item] = at
+	}
+	// Most recently finished first
+	slices.SortFunc(done, func(a, b T) int {
+		return finished[b].Compare(finished[a])
+	})
+	expired := []T{}
+	for i, item := range done {
+		if (keep > 0 && i >= keep) || (ttl > 0 && now.Sub(finished[item]) > ttl) {
+			expired = append(expired, item)
+		}
+	}
+	return expired
+}
+
+// completedAt returns when the last of the jobs finished, or false if any of them haven't completed
+func completedAt(jobs []*Job) (time.Time, bool) {
+	var last time.Time
+	for _, job := range jobs {
+		if !job.isDone() {
+			return time.Time{}, false
+		}
+		if finished := job.Status().Finished; finished.After(last) {
+			last = finished
+		}
+	}
+	return last, true
+}
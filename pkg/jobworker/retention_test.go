@@ -0,0 +1,148 @@
+package jobworker
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// completedJob runs a job to completion and records it as finished at the time
+func completedJob(t *testing.T, finished time.Time) *Job {
+	t.Helper()
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, "-c", "exit 0")
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = job.Wait(ctx); err != nil {
+		t.Fatal("failed to wait for job: ", err)
+	}
+	job.Lock()
+	job.finished = finished
+	job.Unlock()
+	return job
+}
+
+func TestExpired_Applies_Retention_Policy(t *testing.T) {
+	mockUserId()
+	now := time.Now()
+	old := completedJob(t, now.Add(-3*time.Hour))
+	older := completedJob(t, now.Add(-5*time.Hour))
+	recent := completedJob(t, now.Add(-time.Minute))
+	running, err := StartWithController(&mockController{}, JobOpts{}, cmd, "-c", "sleep 30")
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	defer running.Stop(context.Background())
+	jobs := []*Job{old, running, recent, older}
+	tests := map[string]struct {
+		keep     int
+		ttl      time.Duration
+		expected []*Job
+	}{
+		"unlimited": {expected: []*Job{}},
+		"keep":      {keep: 2, expected: []*Job{older}},
+		"ttl":       {ttl: 4 * time.Hour, expected: []*Job{older}},
+		"both":      {keep: 2, ttl: 2 * time.Hour, expected: []*Job{old, older}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if expired := Expired(jobs, tt.keep, tt.ttl, now); !slices.Equal(expired, tt.expected) {
+				t.Errorf("expected %d expired jobs, actual %d", len(tt.expected), len(expired))
+			}
+		})
+	}
+}
+
+func TestJobWorker_Stop_Keeps_Logs_Until_Deleted(t *testing.T) {
+	mockUserId()
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, "-c", "echo hello; sleep 30")
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = job.Stop(ctx); err != nil {
+		t.Fatal("failed to stop job: ", err)
+	}
+	if status := job.Status(); status.State != JobStopped || !status.Retained {
+		t.Errorf("expected stopped job to be retained, actual %+v", status)
+	}
+	// Assert the stopped job's output can still be read
+	reader, err := job.Output(DontFollowLogs)
+	if err != nil {
+		t.Fatal("expected to read stopped job's logs: ", err)
+	}
+	defer reader.Close()
+	if logs, _ := io.ReadAll(reader); string(logs) != "hello\n" {
+		t.Errorf("expected stopped job's logs, actual %q", logs)
+	}
+	if err = job.Delete(); err != nil {
+		t.Fatal("failed to delete job: ", err)
+	}
+	if status := job.Status(); status.Retained {
+		t.Errorf("expected deleted job to not be retained, actual %+v", status)
+	}
+}
+
+func TestJobWorker_Delete_Removes_Completed_Jobs_Logs(t *testing.T) {
+	mockUserId()
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, "-c", "sleep 0.2")
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	if err = job.Delete(); err != ErrJobNotCompleted {
+		t.Errorf("expected running job to not be deleted, actual %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job.Wait(ctx)
+	if _, err = os.Stat(logPath(job.ID)); err != nil {
+		t.Errorf("expected completed job's logs to be kept until it's deleted, error %v", err)
+	}
+	if err = job.Delete(); err != nil {
+		t.Errorf("expected completed job to be deleted, error %v", err)
+	}
+	if _, err = os.Stat(logPath(job.ID)); !os.IsNotExist(err) {
+		t.Errorf("expected deleted job's logs to be removed, error %v", err)
+	}
+}
+
+func TestExpired_Workflows_And_Arrays_Once_Every_Job_Completes(t *testing.T) {
+	mockUserId()
+	now := time.Now()
+	w, err := NewWorkflow(&groupsController{}, "alice", []WorkflowNode{
+		{Name: "a", Command: cmd, Args: []string{"-c", "exit 0"}},
+		{Name: "b", Command: cmd, Args: []string{"-c", "exit 1"}, After: []WorkflowDependency{{Node: "a"}}},
+	}, startJob)
+	if err != nil {
+		t.Fatal("failed to submit workflow: ", err)
+	}
+	waitForWorkflow(t, w)
+	a, err := NewArray(&groupsController{}, "alice", 2, 0, Metadata{}, JobOpts{}, startJob, cmd, "-c", "exit 0")
+	if err != nil {
+		t.Fatal("failed to start array: ", err)
+	}
+	waitForArray(t, a)
+	running, err := NewArray(&groupsController{}, "alice", 1, 0, Metadata{}, JobOpts{}, startJob, cmd, "-c", "sleep 30")
+	if err != nil {
+		t.Fatal("failed to start array: ", err)
+	}
+	defer running.Jobs()[0].Stop(context.Background())
+	// Assert completed workflows and arrays expire with the ttl, while running arrays are kept
+	later := now.Add(2 * time.Hour)
+	if expired := ExpiredWorkflows([]*Workflow{w}, 0, time.Hour, later); !slices.Equal(expired, []*Workflow{w}) {
+		t.Errorf("expected completed workflow to expire, actual %d expired", len(expired))
+	}
+	if expired := ExpiredArrays([]*Array{a, running}, 0, time.Hour, later); !slices.Equal(expired, []*Array{a}) {
+		t.Errorf("expected only the completed array to expire, actual %d expired", len(expired))
+	}
+	if expired := ExpiredArrays([]*Array{a, running}, 1, 0, later); len(expired) != 0 {
+		t.Errorf("expected the most recently completed array to be kept, actual %d expired", len(expired))
+	}
+}
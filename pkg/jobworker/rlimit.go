@@ -0,0 +1,241 @@
+package jobworker
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sys/unix"
+)
+
+// Enforcement describes how strictly a ResourceController applies one of JobOpts resource controls
+type Enforcement string
+
+const (
+	Enforced    Enforcement = "enforced"
+	BestEffort  Enforcement = "best-effort"
+	Unsupported Enforcement = "unsupported"
+)
+
+// Capabilities reports the Enforcement of each resource control, key'd by the cgroup v2 interface file it represents
+type Capabilities map[string]Enforcement
+
+// CapabilityReporter can optionally be implemented by a ResourceController to report how it applies JobOpts, the
+// report is included in the job's status
+type CapabilityReporter interface {
+	Capabilities(JobOpts) Capabilities
+}
+
+// Rlimit implements ResourceController for unprivileged users that can't write to /sys/fs/cgroup. It approximates
+// JobOpts by applying limits to the job's process before it runs the command: memory with RLIMIT_AS, CPU time with
+// RLIMIT_CPU, CPU weight with the process's nice value and IO weight with it's best-effort IO priority. Since these are
+// applied per process rather than a group they are only best-effort.
+type Rlimit struct {
+	sync.Mutex
+	opts map[string]JobOpts
+	cmds map[string]*exec.Cmd
+	// ioprio is false if the kernel doesn't support ioprio_set, IO weight isn't applied
+	ioprio bool
+}
+
+// NewRlimit initialises an Rlimit controller
+func NewRlimit() *Rlimit {
+	_, err := ioprioGet(0)
+	return &Rlimit{opts: map[string]JobOpts{}, cmds: map[string]*exec.Cmd{}, ioprio: err == nil}
+}
+
+// CreateGroup is a no-op since process limits don't have groups
+func (r *Rlimit) CreateGroup(name string) error {
+	return nil
+}
+
+// AddResourceControl stores the job's options to be applied when the process is added
+func (r *Rlimit) AddResourceControl(name string, opts JobOpts) error {
+	r.Lock()
+	defer r.Unlock()
+	r.opts[name] = opts
+	return nil
+}
+
+// AddProcess records the job's cmd, the limits are applied to it's process once started by EnrollProcess
+func (r *Rlimit) AddProcess(name string, cmd *exec.Cmd) error {
+	r.Lock()
+	defer r.Unlock()
+	cmd.SysProcAttr = &syscall.SysProcAttr{CgroupFD: -1}
+	r.cmds[name] = cmd
+	return nil
+}
+
+// EnrollProcess applies the job's limits and priorities to it's process before it runs the command, so they are
+// inherited by the command and any of it's children
+func (r *Rlimit) EnrollProcess(name string, pid int) error {
+	r.Lock()
+	defer r.Unlock()
+	opts := r.opts[name]
+	if opts.MemLimit > 0 {
+		limit := &unix.Rlimit{Cur: uint64(opts.MemLimit), Max: uint64(opts.MemLimit)}
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, limit, nil); err != nil {
+			return fmt.Errorf("failed to set memory limit: %w", err)
+		}
+	}
+	if seconds := uint64(math.Ceil(cpuTime(opts).Seconds())); seconds > 0 {
+		// The soft limit sends SIGXCPU, giving the job a second to exit before the hard limit SIGKILL's it
+		limit := &unix.Rlimit{Cur: seconds, Max: seconds + 1}
+		if err := unix.Prlimit(pid, unix.RLIMIT_CPU, limit, nil); err != nil {
+			return fmt.Errorf("failed to set CPU time limit: %w", err)
+		}
+	}
+	if err := unix.Setpriority(unix.PRIO_PROCESS, pid, niceness(opts.CPUWeight)); err != nil {
+		return fmt.Errorf("failed to set nice value: %w", err)
+	}
+	if r.ioprio {
+		if err := ioprioSet(pid, ioPriority(opts.IOWeight)); err != nil {
+			return fmt.Errorf("failed to set IO priority: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteGroup forgets the job's options
+func (r *Rlimit) DeleteGroup(name string) error {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.opts, name)
+	delete(r.cmds, name)
+	return nil
+}
+
+// KillGroup SIGKILL's the job's process group. Without a cgroup, processes that leave the process group can't be found
+func (r *Rlimit) KillGroup(name string) error {
+	r.Lock()
+	cmd, ok := r.cmds[name]
+	r.Unlock()
+	if !ok || cmd.Process == nil {
+		return nil
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
+
+// SignalGroup signals the job's process group. Without a cgroup, processes that leave the process group can't be found
+func (r *Rlimit) SignalGroup(name string, sig syscall.Signal) error {
+	r.Lock()
+	cmd, ok := r.cmds[name]
+	r.Unlock()
+	if !ok || cmd.Process == nil {
+		return syscall.ESRCH
+	}
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+// Capabilities reports that all of the supported controls are best-effort, CPU max and cpusets are not supported. IO
+// weight is also unsupported if the kernel doesn't support IO priorities
+func (r *Rlimit) Capabilities(opts JobOpts) Capabilities {
+	caps := Capabilities{
+		cpuWeightFile: BestEffort,
+		ioWeightFile:  BestEffort,
+		memHighFile:   BestEffort,
+		cpuMaxFile:    Unsupported,
+		cpusetFile:    Unsupported,
+	}
+	if !r.ioprio {
+		caps[ioWeightFile] = Unsupported
+	}
+	return caps
+}
+
+// cpuTime is the most CPU time the job's process can use before it's Timeout, on it's CPUMax or on every CPU without
+// one. Jobs without a Timeout are limited to RLIMIT_CPU_TIME
+func cpuTime(opts JobOpts) time.Duration {
+	if opts.Timeout <= 0 {
+		return RLIMIT_CPU_TIME
+	}
+	if opts.CPUMax > 0 {
+		return opts.Timeout * time.Duration(opts.CPUMax) / 1000
+	}
+	return opts.Timeout * time.Duration(runtime.NumCPU())
+}
+
+// niceness maps a cgroup v2 cpu.weight to a nice value, where the default weight of 100 is nice 0 and each nice level is
+// ~1.25x the CPU share. Unprivileged users can't lower their nice value so weights above 100 are clamped to 0
+func niceness(weight int32) int {
+	if weight <= 0 {
+		return 0
+	}
+	nice := int(math.Round(-math.Log(float64(weight)/100) / math.Log(1.25)))
+	return min(max(nice, 0), 19)
+}
+
+// ioPriority maps a cgroup v2 io.weight to a best-effort IO priority level, where the default weight of 100 is level 4
+// and each doubling of the weight is one level higher priority
+func ioPriority(weight int32) int {
+	if weight <= 0 {
+		return 4
+	}
+	level := 4 - int(math.Round(math.Log2(float64(weight)/100)))
+	return min(max(level, 0), 7)
+}
+
+// IO priorities are set with the ioprio_set syscall which isn't wrapped by the syscall or unix packages, see
+// linux/ioprio.h
+const (
+	ioprioWhoProcess = 1
+	ioprioClassBE    = 2
+	ioprioClassShift = 13
+)
+
+// ioprioSet sets the process's IO priority to the best-effort level
+func ioprioSet(pid, level int) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), ioprioClassBE<<ioprioClassShift|uintptr(level))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ioprioGet returns the process's IO priority, a pid of 0 is the calling process
+func ioprioGet(pid int) (int, error) {
+	prio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(prio), nil
+}
+
+// SelectController detects the host's cgroup hierarchy and checks a group can be created, falling back to the
+// unprivileged Rlimit controller if cgroups are not available or not writable. When not running as root, cgroup v2
+// groups are created in the subtree delegated to the executing user
+func SelectController(mountinfo string) (ResourceController, error) {
+	con, err := DetectController(mountinfo)
+	if err != nil {
+		return NewRlimit(), err
+	}
+	if cgroup, ok := con.(*Cgroup); ok && os.Geteuid() != 0 {
+		delegated, controllers, err := DelegatedCgroup(cgroup.rootPath)
+		if err != nil {
+			return NewRlimit(), err
+		}
+		log.Printf("using delegated cgroup %s with controllers: %s", delegated.rootPath, strings.Join(controllers, " "))
+		con = delegated
+	}
+	// Probe the controller by creating and deleting a group
+	probe := fmt.Sprintf("jobworker-probe-%s", uuid.New().String())
+	if err = con.CreateGroup(probe); err != nil {
+		con.DeleteGroup(probe)
+		return NewRlimit(), err
+	}
+	if err = con.DeleteGroup(probe); err != nil {
+		return NewRlimit(), err
+	}
+	return con, nil
+}
@@ -0,0 +1,116 @@
+package jobworker
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRlimit_Applies_Process_Limits(t *testing.T) {
+	mockUserId()
+	// Print the job's virtual memory limit in KB, CPU time limit in seconds, nice value and the command it's running
+	args := []string{"-c", `ulimit -v; ulimit -t; nice; tr '\0' ' ' < /proc/$$/cmdline; echo; sleep 30`}
+	opts := JobOpts{CPUWeight: 50, IOWeight: 200, MemLimit: 512 * CgroupMB, CPUMax: 500, Timeout: 10 * time.Second}
+
+	// Run the job
+	job, err := StartWithController(NewRlimit(), opts, cmd, args...)
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	defer os.Remove(logPath(job.ID))
+	time.Sleep(100 * time.Millisecond)
+
+	// Assert the IO priority was applied to the job's process, best-effort class at level 3
+	if prio, err := ioprioGet(int(job.Status().PID)); err != nil || prio != ioprioClassBE<<ioprioClassShift|3 {
+		t.Errorf("expected job's IO priority to be best-effort level 3, actual %#x, error %v", prio, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = job.Stop(ctx); err != nil {
+		t.Fatal("failed to stop job: ", err)
+	}
+
+	// Assert the limits were applied to the job's process without wrapping it's command
+	reader, err := job.Output(DontFollowLogs)
+	if err != nil {
+		t.Fatal("could not get reader for job's output")
+	}
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	logs := []string{}
+	for scanner.Scan() {
+		logs = append(logs, scanner.Text())
+	}
+	expected := []string{"524288", "5", "3", strings.Join(append([]string{cmd}, args...), " ") + " "}
+	if !slices.Equal(logs, expected) {
+		t.Errorf("expected output %q, actual %q", expected, logs)
+	}
+	// Assert the status reports the limits are best-effort
+	status := job.Status()
+	if status.Capabilities[memHighFile] != BestEffort || status.Capabilities[cpuMaxFile] != Unsupported {
+		t.Errorf("expected capabilities to report best-effort limits, actual %v", status.Capabilities)
+	}
+}
+
+func TestRlimit_Reports_IO_Weight_Unsupported_Without_IO_Priorities(t *testing.T) {
+	r := NewRlimit()
+	r.ioprio = false
+	if caps := r.Capabilities(JobOpts{}); caps[ioWeightFile] != Unsupported || caps[cpuWeightFile] != BestEffort {
+		t.Errorf("expected IO weight to be unsupported, actual %v", caps)
+	}
+}
+
+func TestRlimit_CPU_Time_Mapping(t *testing.T) {
+	RLIMIT_CPU_TIME = time.Hour
+	defer func() { RLIMIT_CPU_TIME = 0 }()
+	tests := map[string]struct {
+		opts     JobOpts
+		expected time.Duration
+	}{
+		"no timeout": {opts: JobOpts{CPUMax: 500}, expected: time.Hour},
+		"cpu max":    {opts: JobOpts{CPUMax: 1500, Timeout: 10 * time.Second}, expected: 15 * time.Second},
+		"every cpu":  {opts: JobOpts{Timeout: 10 * time.Second}, expected: time.Duration(runtime.NumCPU()) * 10 * time.Second},
+	}
+	for name, tt := range tests {
+		if actual := cpuTime(tt.opts); actual != tt.expected {
+			t.Errorf("%s: expected CPU time %v, actual %v", name, tt.expected, actual)
+		}
+	}
+}
+
+func TestRlimit_Priority_Mapping(t *testing.T) {
+	niceValues := map[int32]int{1: 19, 50: 3, 100: 0, 1000: 0}
+	for weight, expected := range niceValues {
+		if nice := niceness(weight); nice != expected {
+			t.Errorf("expected cpu weight %d to be nice %d, actual %d", weight, expected, nice)
+		}
+	}
+	ioLevels := map[int32]int{1: 7, 50: 5, 100: 4, 400: 2, 10000: 0}
+	for weight, expected := range ioLevels {
+		if level := ioPriority(weight); level != expected {
+			t.Errorf("expected io weight %d to be level %d, actual %d", weight, expected, level)
+		}
+	}
+}
+
+func TestSelectController_Falls_Back_To_Rlimit(t *testing.T) {
+	// cgroup2 "mounted" in a directory that doesn't exist so creating a group fails
+	mountinfo := filepath.Join(t.TempDir(), "mountinfo")
+	err := os.WriteFile(mountinfo, []byte("35 24 0:30 / /nonexistent/cgroup rw,relatime - cgroup2 cgroup2 rw\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	con, err := SelectController(mountinfo)
+	if err == nil {
+		t.Error("expected error explaining why cgroups could not be used")
+	}
+	if _, ok := con.(*Rlimit); !ok {
+		t.Errorf("expected Rlimit controller, actual %T", con)
+	}
+}
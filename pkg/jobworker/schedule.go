@@ -0,0 +1,232 @@
+package jobworker
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConcurrencyPolicy determines what a Schedule does when it's due while a previous run is still running
+type ConcurrencyPolicy string
+
+const (
+	AllowConcurrent   ConcurrencyPolicy = "allow"   // launch the new run alongside the previous runs, the default
+	ForbidConcurrent  ConcurrencyPolicy = "forbid"  // skip the new run
+	ReplaceConcurrent ConcurrencyPolicy = "replace" // stop the previous runs then launch the new run
+)
+
+// StartFunc starts a job, such as StartWithController with a given ResourceController
+type StartFunc func(opts JobOpts, cmd string, args ...string) (*Job, error)
+
+// Schedule launches a fresh job from a template command and JobOpts each time it's Cron is due, recording the ID of
+// every run
+type Schedule struct {
+	sync.Mutex
+	ID      string
+	Spec    string
+	Command string
+	Args    []string
+	Opts    JobOpts
+	Policy  ConcurrencyPolicy
+	cron    Cron
+	start   StartFunc
+	paused  bool
+	deleted bool
+	next    time.Time
+	runs    []string
+	active  []*Job
+	wake    chan bool
+}
+
+// ScheduleStatus is a snapshot of a Schedule
+type ScheduleStatus struct {
+	ID      string
+	Spec    string
+	Command string
+	Args    []string
+	Policy  ConcurrencyPolicy
+	Paused  bool
+	Next    time.Time // zero while paused
+	Runs    []string  // IDs of the jobs launched, oldest first
+}
+
+// NewSchedule parses the cron spec and runs the schedule in a go routine, launching jobs using start until Delete
+// is called
+func NewSchedule(spec string, policy ConcurrencyPolicy, start StartFunc, opts JobOpts, cmd string, args ...string) (*Schedule, error) {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch policy {
+	case "":
+		policy = AllowConcurrent
+	case AllowConcurrent, ForbidConcurrent, ReplaceConcurrent:
+	default:
+		return nil, fmt.Errorf("concurrency policy %q not valid", policy)
+	}
+	s := &Schedule{
+		ID:      uuid.New().String(),
+		Spec:    spec,
+		Command: cmd,
+		Args:    args,
+		Opts:    opts,
+		Policy:  policy,
+		cron:    cron,
+		start:   start,
+		runs:    []string{},
+		active:  []*Job{},
+		wake:    make(chan bool, 1),
+	}
+	s.next = cron.Next(time.Now())
+	go s.run()
+	return s, nil
+}
+
+// Pause stops the schedule launching jobs until Resume is called, jobs already running are not affected
+func (s *Schedule) Pause() {
+	s.Lock()
+	s.paused = true
+	s.Unlock()
+	s.notify()
+}
+
+// Resume continues launching jobs from the next time the schedule is due
+func (s *Schedule) Resume() {
+	s.Lock()
+	s.paused = false
+	s.next = s.cron.Next(time.Now())
+	s.Unlock()
+	s.notify()
+}
+
+// Delete stops the schedule permanently, jobs already running are not affected
+func (s *Schedule) Delete() {
+	s.Lock()
+	s.deleted = true
+	s.Unlock()
+	s.notify()
+}
+
+// ForgetRuns removes runs from the schedule's history unless they're still active or retained, i.e. once their jobs
+// have been deleted, so the history doesn't grow for the lifetime of the schedule
+func (s *Schedule) ForgetRuns(retained func(jobID string) bool) {
+	s.Lock()
+	defer s.Unlock()
+	runs := []string{}
+	for _, id := range s.runs {
+		if retained(id) || slices.ContainsFunc(s.active, func(job *Job) bool { return job.ID == id }) {
+			runs = append(runs, id)
+		}
+	}
+	s.runs = runs
+}
+
+// Status returns a snapshot of the schedule
+func (s *Schedule) Status() ScheduleStatus {
+	s.Lock()
+	defer s.Unlock()
+	status := ScheduleStatus{
+		ID:      s.ID,
+		Spec:    s.Spec,
+		Command: s.Command,
+		Args:    s.Args,
+		Policy:  s.Policy,
+		Paused:  s.paused,
+		Runs:    append([]string{}, s.runs...),
+	}
+	if !s.paused {
+		status.Next = s.next
+	}
+	return status
+}
+
+// notify wakes the schedule's go routine to re-evaluate when it's next due
+func (s *Schedule) notify() {
+	select {
+	case s.wake <- true:
+	default:
+	}
+}
+
+// run waits until the schedule is due and launches a job, until the schedule is deleted
+func (s *Schedule) run() {
+	for {
+		s.Lock()
+		if s.deleted {
+			s.Unlock()
+			return
+		}
+		scheduled := !s.paused && !s.next.IsZero()
+		wait := time.Until(s.next)
+		s.Unlock()
+		if !scheduled {
+			<-s.wake
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+			s.tick()
+		}
+	}
+}
+
+// tick launches a job according to the schedule's ConcurrencyPolicy and works out when the schedule is next due.
+// Previous runs being replaced are stopped, and the new run started, without holding the lock so the schedule can be
+// used meanwhile, i.e. by the job's Hooks
+func (s *Schedule) tick() {
+	s.Lock()
+	if s.deleted || s.paused {
+		s.Unlock()
+		return
+	}
+	s.next = s.cron.Next(time.Now())
+	// Forget runs that have completed, runs waiting in a Queue are still active
+	running := []*Job{}
+	for _, job := range s.active {
+		if !job.isDone() {
+			running = append(running, job)
+		}
+	}
+	s.active = running
+	replaced := []*Job{}
+	switch s.Policy {
+	case ForbidConcurrent:
+		if len(s.active) > 0 {
+			fmt.Printf("schedule %s skipped run, previous run still running\n", s.ID)
+			s.Unlock()
+			return
+		}
+	case ReplaceConcurrent:
+		replaced = s.active
+		s.active = []*Job{}
+	}
+	s.Unlock()
+	for _, job := range replaced {
+		if err := job.Stop(context.Background()); err != nil {
+			fmt.Printf("schedule %s failed to stop previous run %s: %v\n", s.ID, job.ID, err)
+		}
+	}
+	// The schedule may have been deleted while the previous runs were stopping
+	s.Lock()
+	deleted := s.deleted
+	s.Unlock()
+	if deleted {
+		return
+	}
+	job, err := s.start(s.Opts, s.Command, s.Args...)
+	if err != nil {
+		fmt.Printf("schedule %s failed to start run: %v\n", s.ID, err)
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.runs = append(s.runs, job.ID)
+	s.active = append(s.active, job)
+}
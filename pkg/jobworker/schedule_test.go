@@ -0,0 +1,176 @@
+package jobworker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSchedule_Launches_Jobs_Each_Tick(t *testing.T) {
+	mockUserId()
+	start := func(opts JobOpts, cmd string, args ...string) (*Job, error) {
+		return StartWithController(&mockController{}, opts, cmd, args...)
+	}
+	s, err := NewSchedule("@every 1s", AllowConcurrent, start, JobOpts{}, cmd, "-c", "echo hello")
+	if err != nil {
+		t.Fatal("failed to create schedule: ", err)
+	}
+	defer s.Delete()
+
+	time.Sleep(2500 * time.Millisecond)
+
+	// Assert a job was launched at each tick
+	status := s.Status()
+	if len(status.Runs) != 2 {
+		t.Errorf("expected 2 runs, actual %v", status.Runs)
+	}
+	// Assert pausing stops launching jobs
+	s.Pause()
+	time.Sleep(1100 * time.Millisecond)
+	status = s.Status()
+	if !status.Paused || !status.Next.IsZero() || len(status.Runs) != 2 {
+		t.Errorf("expected paused schedule not to launch jobs, actual %+v", status)
+	}
+}
+
+func TestSchedule_Can_Be_Used_While_Starting_A_Run(t *testing.T) {
+	mockUserId()
+	var schedule atomic.Pointer[Schedule]
+	statuses := make(chan ScheduleStatus, 1)
+	start := func(opts JobOpts, cmd string, args ...string) (*Job, error) {
+		// Starting a run, i.e. it's hooks, may call back into the schedule
+		select {
+		case statuses <- schedule.Load().Status():
+		default:
+		}
+		return StartWithController(&mockController{}, opts, cmd, args...)
+	}
+	s, err := NewSchedule("@every 1s", AllowConcurrent, start, JobOpts{}, cmd, "-c", "exit 0")
+	if err != nil {
+		t.Fatal("failed to create schedule: ", err)
+	}
+	schedule.Store(s)
+	defer s.Delete()
+	select {
+	case <-statuses:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the schedule's status to be read while starting a run")
+	}
+	// Wait for the run to be recorded so it isn't still starting once the test completes
+	for deadline := time.Now().Add(time.Second); len(s.Status().Runs) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if runs := s.Status().Runs; len(runs) != 1 {
+		t.Errorf("expected 1 run, actual %v", runs)
+	}
+}
+
+func TestSchedule_Forgets_Runs_That_Are_Not_Retained(t *testing.T) {
+	mockUserId()
+	start := func(opts JobOpts, cmd string, args ...string) (*Job, error) {
+		return StartWithController(&mockController{}, opts, cmd, args...)
+	}
+	s, err := NewSchedule("@every 1s", AllowConcurrent, start, JobOpts{}, cmd, "-c", "exit 0")
+	if err != nil {
+		t.Fatal("failed to create schedule: ", err)
+	}
+	defer s.Delete()
+	time.Sleep(2500 * time.Millisecond)
+	s.Pause()
+	runs := s.Status().Runs
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, actual %v", runs)
+	}
+	// Assert only the retained run is kept in the history
+	s.ForgetRuns(func(id string) bool { return id == runs[1] })
+	if status := s.Status(); len(status.Runs) != 1 || status.Runs[0] != runs[1] {
+		t.Errorf("expected only run %s to be kept, actual %v", runs[1], status.Runs)
+	}
+}
+
+func TestSchedule_Concurrency_Policy(t *testing.T) {
+	mockUserId()
+	var mu sync.Mutex
+	started := []*Job{}
+	start := func(opts JobOpts, cmd string, args ...string) (*Job, error) {
+		job, err := StartWithController(&mockController{}, opts, cmd, args...)
+		if err == nil {
+			mu.Lock()
+			started = append(started, job)
+			mu.Unlock()
+		}
+		return job, err
+	}
+	// Run a job that's longer than the interval so the first run is still running at the second tick
+	s, err := NewSchedule("@every 1s", ForbidConcurrent, start, JobOpts{}, cmd, "-c", "sleep 30")
+	if err != nil {
+		t.Fatal("failed to create schedule: ", err)
+	}
+	time.Sleep(2500 * time.Millisecond)
+	s.Delete()
+	if runs := s.Status().Runs; len(runs) != 1 {
+		t.Errorf("expected forbid policy to skip runs while the first is running, actual %v", runs)
+	}
+
+	// Assert the replace policy stops the previous run
+	s, err = NewSchedule("@every 1s", ReplaceConcurrent, start, JobOpts{}, cmd, "-c", "sleep 30")
+	if err != nil {
+		t.Fatal("failed to create schedule: ", err)
+	}
+	time.Sleep(2500 * time.Millisecond)
+	s.Delete()
+	if runs := s.Status().Runs; len(runs) != 2 {
+		t.Fatalf("expected replace policy to launch every run, actual %v", runs)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if started[1].Status().Running || !started[2].Status().Running {
+		t.Error("expected replace policy to stop the previous run")
+	}
+	for _, job := range started {
+		if job.Status().Running {
+			job.Stop(context.Background())
+		}
+	}
+}
+
+func TestSchedule_Replace_Does_Not_Block_While_Stopping(t *testing.T) {
+	mockUserId()
+	var mu sync.Mutex
+	started := []*Job{}
+	start := func(opts JobOpts, cmd string, args ...string) (*Job, error) {
+		job, err := StartWithController(&mockController{}, opts, cmd, args...)
+		if err == nil {
+			mu.Lock()
+			started = append(started, job)
+			mu.Unlock()
+		}
+		return job, err
+	}
+	// The first run ignores SIGTERM, so replacing it at the second tick waits for the stop grace period
+	s, err := NewSchedule("@every 1s", ReplaceConcurrent, start, JobOpts{}, cmd, "-c", "trap '' TERM; while true; do sleep 0.1; done")
+	if err != nil {
+		t.Fatal("failed to create schedule: ", err)
+	}
+	time.Sleep(2500 * time.Millisecond)
+	begin := time.Now()
+	s.Status()
+	s.Delete()
+	if elapsed := time.Since(begin); elapsed > 100*time.Millisecond {
+		t.Errorf("expected schedule to be usable while replacing a run, blocked for %s", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, job := range started {
+		job.signal(syscall.SIGKILL)
+	}
+}
+
+func TestNewSchedule_Invalid_Policy(t *testing.T) {
+	if _, err := NewSchedule("@hourly", "sometimes", nil, JobOpts{}, cmd); err == nil {
+		t.Error("expected invalid concurrency policy to be rejected")
+	}
+}
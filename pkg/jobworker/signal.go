@@ -0,0 +1,113 @@
+package jobworker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrJobNotRunning is returned when signalling a job that is pending or has completed
+var ErrJobNotRunning = errors.New("job is not running")
+
+// SignalTarget determines which of a job's processes Job.Signal signals
+type SignalTarget string
+
+const (
+	SignalProcess      SignalTarget = "process" // the command's main process
+	SignalProcessGroup SignalTarget = "group"   // the command's process group, the default
+	SignalCgroup       SignalTarget = "cgroup"  // every process in the job's cgroup, including any that left the process group
+)
+
+// GroupSignaler can optionally be implemented by a ResourceController to signal every process in a job's group, it's
+// required to signal a job with the SignalCgroup target
+type GroupSignaler interface {
+	SignalGroup(name string, sig syscall.Signal) error
+}
+
+// signals are the signals ParseSignal accepts by name
+var signals = map[string]syscall.Signal{
+	"HUP":    syscall.SIGHUP,
+	"INT":    syscall.SIGINT,
+	"QUIT":   syscall.SIGQUIT,
+	"ABRT":   syscall.SIGABRT,
+	"KILL":   syscall.SIGKILL,
+	"USR1":   syscall.SIGUSR1,
+	"USR2":   syscall.SIGUSR2,
+	"PIPE":   syscall.SIGPIPE,
+	"ALRM":   syscall.SIGALRM,
+	"TERM":   syscall.SIGTERM,
+	"CONT":   syscall.SIGCONT,
+	"STOP":   syscall.SIGSTOP,
+	"TSTP":   syscall.SIGTSTP,
+	"TTIN":   syscall.SIGTTIN,
+	"TTOU":   syscall.SIGTTOU,
+	"URG":    syscall.SIGURG,
+	"XCPU":   syscall.SIGXCPU,
+	"XFSZ":   syscall.SIGXFSZ,
+	"VTALRM": syscall.SIGVTALRM,
+	"PROF":   syscall.SIGPROF,
+	"WINCH":  syscall.SIGWINCH,
+	"IO":     syscall.SIGIO,
+	"PWR":    syscall.SIGPWR,
+	"SYS":    syscall.SIGSYS,
+}
+
+// ParseSignal parses a signal by name, with or without the SIG prefix, or by number, e.g. "HUP", "SIGUSR1" or "15"
+func ParseSignal(value string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		for _, sig := range signals {
+			if int(sig) == n {
+				return sig, nil
+			}
+		}
+		return 0, fmt.Errorf("signal %q not valid", value)
+	}
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(value), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("signal %q not valid", value)
+	}
+	return sig, nil
+}
+
+// SignalAllowed returns true if owners are permitted to send the signal, see ALLOWED_SIGNALS
+func SignalAllowed(sig syscall.Signal) bool {
+	return slices.Contains(ALLOWED_SIGNALS, sig)
+}
+
+// Signal sends a signal to a running job's main process, process group or every process in it's cgroup. Unlike Stop,
+// the job is not cleaned up if the signal terminates it's command, and a job with a RestartPolicy may be restarted.
+func (job *Job) Signal(sig syscall.Signal, target SignalTarget) error {
+	job.RLock()
+	running, cmd := job.running, job.cmd
+	job.RUnlock()
+	if !running {
+		return ErrJobNotRunning
+	}
+	switch target {
+	case SignalProcess:
+		// The job may be waiting to be restarted, without a process
+		if cmd.Process == nil {
+			return syscall.ESRCH
+		}
+		if err := cmd.Process.Signal(sig); errors.Is(err, os.ErrProcessDone) {
+			return syscall.ESRCH
+		} else if err != nil {
+			return err
+		}
+		return nil
+	case "", SignalProcessGroup:
+		return job.signal(sig)
+	case SignalCgroup:
+		signaler, ok := job.con.(GroupSignaler)
+		if !ok {
+			return fmt.Errorf("%T does not support signalling the job's cgroup", job.con)
+		}
+		return signaler.SignalGroup(job.ID, sig)
+	default:
+		return fmt.Errorf("signal target %q not valid", target)
+	}
+}
@@ -0,0 +1,80 @@
+package jobworker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := map[string]syscall.Signal{
+		"HUP":     syscall.SIGHUP,
+		"SIGUSR1": syscall.SIGUSR1,
+		"int":     syscall.SIGINT,
+		"15":      syscall.SIGTERM,
+	}
+	for value, expected := range tests {
+		if sig, err := ParseSignal(value); err != nil || sig != expected {
+			t.Errorf("expected %q to parse as %v, actual %v error %v", value, expected, sig, err)
+		}
+	}
+	for _, value := range []string{"", "SIGFOO", "0", "999"} {
+		if _, err := ParseSignal(value); err == nil {
+			t.Errorf("expected %q to not be a valid signal", value)
+		}
+	}
+}
+
+func TestJobWorker_Signal_Reaches_Job(t *testing.T) {
+	mockUserId()
+	args := []string{"-c", "trap 'echo reloaded' HUP; while true; do sleep 0.1; done"}
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, args...)
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer job.Stop(ctx)
+	// Give bash time to install the trap
+	time.Sleep(100 * time.Millisecond)
+	for _, target := range []SignalTarget{SignalProcess, SignalProcessGroup} {
+		if err = job.Signal(syscall.SIGHUP, target); err != nil {
+			t.Errorf("expected to be able to signal the job's %s, error: %v", target, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	// Assert the trap ran for each signal and the job is still running
+	reader, err := job.Output(DontFollowLogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	reloads := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), "reloaded") {
+			reloads++
+		}
+	}
+	if reloads != 2 || !job.Status().Running {
+		t.Errorf("expected job to handle 2 signals and keep running, actual %d", reloads)
+	}
+	// mockController can't signal the job's cgroup
+	if err = job.Signal(syscall.SIGHUP, SignalCgroup); err == nil {
+		t.Error("expected signalling the cgroup to not be supported")
+	}
+	if err = job.Signal(syscall.SIGHUP, "pid"); err == nil {
+		t.Error("expected an invalid target to be rejected")
+	}
+	// Assert a completed job can't be signalled
+	if err = job.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = job.Signal(syscall.SIGHUP, SignalProcessGroup); !errors.Is(err, ErrJobNotRunning) {
+		t.Errorf("expected %v signalling a stopped job, actual %v", ErrJobNotRunning, err)
+	}
+}
@@ -1,6 +1,8 @@
 package jobworker
 
 import (
+	"bufio"
+	"bytes"
 	"io"
 	"os"
 	"sync"
@@ -50,3 +52,52 @@ func newTailReader(fileName string, pollInterval time.Duration, mode OutputMode)
 	}
 	return &tailReader{f: f, pollInterval: pollInterval, mode: mode}, nil
 }
+
+// readLines calls handle with each line of the job's logs once it has started, without the trailing newline. The log
+// file is read directly rather than with Job.Output, so with FollowLogs lines are read until the job completes even if
+// it completes before the file is opened. Reading stops early once closed is closed or handle returns an error
+func (job *Job) readLines(mode OutputMode, closed <-chan struct{}, handle func(line []byte) error) {
+	// Jobs that are waiting haven't created their logs yet, and jobs cancelled before starting have none
+	for job.Status().Started.IsZero() {
+		if mode == DontFollowLogs || job.isDone() {
+			return
+		}
+		select {
+		case <-closed:
+			return
+		case <-job.done:
+		case <-time.After(TAIL_POLL_INTERVAL):
+		}
+	}
+	f, err := os.Open(logPath(job.ID))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	logs := bufio.NewReader(f)
+	line := []byte{}
+	for {
+		// Once the job has completed, reaching the end of it's logs means every line has been read
+		completed := mode == DontFollowLogs || job.isDone()
+		chunk, err := logs.ReadBytes('\n')
+		line = append(line, chunk...)
+		if err == nil {
+			if handle(bytes.TrimSuffix(line, []byte("\n"))) != nil {
+				return
+			}
+			line = []byte{}
+			continue
+		}
+		if err != io.EOF || completed {
+			if len(line) > 0 {
+				handle(line)
+			}
+			return
+		}
+		select {
+		case <-closed:
+			return
+		case <-time.After(TAIL_POLL_INTERVAL):
+		}
+	}
+}
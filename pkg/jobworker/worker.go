@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -21,40 +25,157 @@ const (
 	FollowLogs     OutputMode = 1
 )
 
-// Job maintains the exec.Cmd struct (containing the underlying os.Process) and the "owner" for authz
+// ExitReason describes why a job's process is no longer running
+type ExitReason string
+
+const (
+	Exited      ExitReason = "exited"       // the command exited by itself
+	Stopped     ExitReason = "stopped"      // the job was stopped by a call to Stop
+	TimedOut    ExitReason = "timed-out"    // the job was stopped after running for longer than it's Timeout
+	StartFailed ExitReason = "start-failed" // the job's cgroup or command could not be started after being queued, or restarted
+	Skipped     ExitReason = "skipped"      // the job was never started since a job it depends on did not succeed
+)
+
+// JobState summarises where a job is in it's lifecycle
+type JobState string
+
+const (
+	JobPending  JobState = "pending"  // waiting for the jobs it depends on or a free slot in a Queue
+	JobRunning  JobState = "running"  // the command is running, or waiting to be restarted
+	JobStopping JobState = "stopping" // the job is being stopped, see StopAsync
+	JobExited   JobState = "exited"   // the command exited by itself with code 0
+	JobStopped  JobState = "stopped"  // the job was stopped by a call to Stop, or skipped since a job it depends on did not succeed
+	JobFailed   JobState = "failed"   // the command exited with a non zero code, timed out or failed to start
+)
+
+// RestartPolicy determines if a job's command is run again after it exits
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"      // the job completes when the command exits, the default
+	RestartOnFailure RestartPolicy = "on-failure" // the command is restarted if it exits with a non zero exit code
+	RestartAlways    RestartPolicy = "always"     // the command is restarted whenever it exits
+)
+
+// Job maintains the exec.Cmd struct (containing the underlying os.Process) and the "owner" for authz. If the job is
+// restarted, cmd is replaced with a new exec.Cmd for each run of the command
 type Job struct {
 	sync.RWMutex
-	ID      string
-	pgid    int
-	running bool
-	done    chan bool
-	cmd     *exec.Cmd
-	readers []io.ReadCloser
-	con     ResourceController
+	ID       string
+	owner    string
+	pgid     int
+	pending  bool
+	running  bool
+	reason   ExitReason
+	done     chan bool // closed once the job has completed, see Wait
+	stopping chan bool // closed once the job starts being stopped
+	stopped  chan bool // closed once the job has been stopped and cleaned up
+	stopOnce sync.Once
+	announce sync.Once // publishes EventCreated once the job is first submitted
+	stopErr  error
+	path     string
+	args     []string
+	opts     JobOpts
+	cmd      *exec.Cmd
+	logFile  *os.File
+	restarts int32
+	lastExit int32
+	oomKills int // OOM kills last reported by the ResourceController, see OOMReporter
+	readers  []io.ReadCloser
+	con      ResourceController
+	caps     Capabilities
+	queue    *Queue
+	waiting  bool     // waiting for the jobs it depends on to complete or a slot in it's Array, see StartAfter
+	env      []string // environment of the command, see SetEnv
+	deleted  bool     // the job's logs and cgroup have been removed, see Delete
+	metadata Metadata // name, labels and annotations, see SetMetadata
+	hooks    *hookRunner
+	created  time.Time
+	started  time.Time // when the command was first launched
+	finished time.Time
 }
 
 // JobOpts wraps the options that can be passed to cgroups for the job
 // details at https://facebookmicrosites.github.io/cgroup2/docs/overview
 type JobOpts struct {
-	CPUWeight int32      // `cpu.weight`
-	IOWeight  int32      // `io.weight`
-	MemLimit  CgroupByte // `mem.high`
+	CPUWeight int32         // `cpu.weight`
+	IOWeight  int32         // `io.weight`
+	MemLimit  CgroupByte    // `mem.high`
+	CPUMax    int32         // `cpu.max` in millicores, where 1000 is 1 CPU. 0 for no limit
+	CPUSet    string        // `cpuset.cpus`, e.g. "0-3,6". Empty for all CPUs
+	Timeout   time.Duration // maximum runtime before the job is stopped, 0 for no limit
+	// RestartPolicy and MaxRetries determine if the command is run again after it exits, MaxRetries of 0 restarts
+	// without limit. Restarts are delayed with exponential backoff, see RESTART_BACKOFF and RESTART_BACKOFF_MAX, and
+	// the job fails with StartFailed if it's command can't be launched RESTART_MAX_LAUNCH_FAILURES times in a row
+	RestartPolicy RestartPolicy
+	MaxRetries    int32
+	// Priority orders jobs waiting in a Queue with PriorityOrder, higher runs first
+	Priority int32
 }
 
 // JobStatus is an amalgamation of the useful status information available from the exec.Cmd struct of the job and it's underlying os.Process
 type JobStatus struct {
-	ID       string
-	PID      int64
-	Running  bool
-	ExitCode int32
+	ID            string
+	Owner         string
+	Name          string
+	Command       string
+	Args          []string
+	Opts          JobOpts
+	State         JobState
+	Created       time.Time
+	Started       time.Time     // zero until the command is launched
+	Finished      time.Time     // zero until the job completes
+	Duration      time.Duration // time since the command was launched, until the job completed
+	PID           int64
+	Running       bool
+	Pending       bool  // waiting for the jobs it depends on to complete, or a slot in it's Array
+	Queued        bool  // waiting in a Queue for a free slot
+	QueuePosition int32 // 1 for the next job to start, only set while queued
+	ExitCode      int32
+	ExitReason    ExitReason   // why the job is no longer running, empty while it's running
+	Restarts      int32        // number of times the command has been restarted
+	LastExitCode  int32        // exit code of the previous run of the command, only set if it has been restarted
+	Capabilities  Capabilities // how the job's resource controls are enforced, nil if not reported by the controller
+	Retained      bool         // the job has completed and it's logs are kept until it's deleted
+	Labels        map[string]string
+	Annotations   map[string]string
 }
 
 func (status JobStatus) String() string {
-	return fmt.Sprintf(`Job Status
+	s := fmt.Sprintf(`Job Status
 	ID	%s
+	Owner	%s
+	Command	%s %v
+	State	%s
 	PID	%d
 	Running	%t
-	ExitCode %d`, status.ID, status.PID, status.Running, status.ExitCode)
+	ExitCode %d`, status.ID, status.Owner, status.Command, status.Args, status.State, status.PID, status.Running, status.ExitCode)
+	if status.Pending {
+		s += "\n\tPending true"
+	}
+	if status.Queued {
+		s += fmt.Sprintf("\n\tQueuePosition %d", status.QueuePosition)
+	}
+	if status.ExitReason != "" {
+		s += fmt.Sprintf("\n\tExitReason %s", status.ExitReason)
+	}
+	if status.Restarts > 0 {
+		s += fmt.Sprintf("\n\tRestarts %d\n\tLastExitCode %d", status.Restarts, status.LastExitCode)
+	}
+	if status.Retained {
+		s += "\n\tRetained true"
+	}
+	controls := []string{}
+	for control := range status.Capabilities {
+		controls = append(controls, control)
+	}
+	slices.Sort(controls)
+	for _, control := range controls {
+		if status.Capabilities[control] != Enforced {
+			s += fmt.Sprintf("\n\t%s %s", control, status.Capabilities[control])
+		}
+	}
+	return s
 }
 
 // ResourceController defines the interface for implementing resource control of new processes
@@ -64,11 +185,31 @@ type ResourceController interface {
 	CreateGroup(string) error
 	DeleteGroup(string) error
 	AddResourceControl(string, JobOpts) error
+	KillGroup(string) error
+}
+
+// ProcessEnroller can optionally be implemented by a ResourceController that can only apply it's controls to a job's
+// process once it has started, e.g. cgroup v1 doesn't support CLONE_INTO_CGROUP. The started process is held before it
+// runs the job's command until EnrollProcess returns, and is killed if it returns an error
+type ProcessEnroller interface {
+	EnrollProcess(name string, pid int) error
 }
 
 // NewJob initialises a Job
 func NewJob(id string, cmd *exec.Cmd, con ResourceController) *Job {
-	return &Job{ID: id, running: true, cmd: cmd, con: con, done: make(chan bool, 1), readers: []io.ReadCloser{}}
+	return &Job{
+		ID:       id,
+		running:  true,
+		path:     cmd.Path,
+		args:     cmd.Args,
+		cmd:      cmd,
+		con:      con,
+		done:     make(chan bool),
+		stopping: make(chan bool),
+		stopped:  make(chan bool),
+		readers:  []io.ReadCloser{},
+		created:  time.Now(),
+	}
 }
 
 // Start calls start using the default ResourceController Cgroup
@@ -76,141 +217,669 @@ func Start(opts JobOpts, cmd string, args ...string) (j *Job, err error) {
 	return StartWithController(&Cgroup{"/sys/fs/cgroup"}, opts, cmd, args...)
 }
 
-// StartWithController creates a job's cgroup, adds the resource controls from opts, creates a log file for the cgroup and
-// set's it to the exec.Cmd STDOUT and STDERR. Finally we Start the exec.Cmd and start a go routine to handle the blocking
-// call to Wait so that we can update the job's running flag.
+// StartWithController creates a job using NewJobWithController and starts it
 func StartWithController(con ResourceController, opts JobOpts, cmd string, args ...string) (j *Job, err error) {
-	// Create the job
-	j = NewJob(uuid.New().String(), exec.Command(cmd, args...), con)
+	j = NewJobWithController(con, opts, cmd, args...)
+	if err = j.Start(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// NewJobWithController initialises a pending Job for the command and options, nothing is created for the job until
+// Start is called, i.e. once a Queue has a free slot
+func NewJobWithController(con ResourceController, opts JobOpts, cmd string, args ...string) *Job {
+	j := NewJob(uuid.New().String(), exec.Command(cmd, args...), con)
+	j.opts = opts
+	j.pending = true
+	j.running = false
+	return j
+}
+
+// Start creates a pending job's cgroup, adds the resource controls from opts and creates a log file for the job's
+// output before launching the command. If opts has a Timeout, the job is stopped once it passes. If the job fails to
+// start, anything created for it is removed and the job completes with the exit reason StartFailed.
+func (job *Job) Start() (err error) {
+	job.Lock()
+	if !job.pending || job.reason != "" {
+		job.Unlock()
+		return fmt.Errorf("job %s has already been started or stopped", job.ID)
+	}
+	job.pending = false
+	job.running = true
+	job.Unlock()
+	job.publishCreated()
+	defer func() {
+		if err != nil {
+			job.abort()
+		}
+	}()
 
 	// Create the cgroup and configure the controllers
-	if err = j.con.CreateGroup(j.ID); err != nil {
-		return nil, fmt.Errorf("failed to create cgroup: %w", err)
+	if err = job.con.CreateGroup(job.ID); err != nil {
+		return fmt.Errorf("failed to create cgroup: %w", err)
 	}
 	// Update cgroup controllers to add resource control to process
-	if err = j.con.AddResourceControl(j.ID, opts); err != nil {
-		return nil, fmt.Errorf("failed to add resource control: %w", err)
+	if err = job.con.AddResourceControl(job.ID, job.opts); err != nil {
+		return fmt.Errorf("failed to add resource control: %w", err)
+	}
+	if reporter, ok := job.con.(CapabilityReporter); ok {
+		job.caps = reporter.Capabilities(job.opts)
+	}
+	// Pipe STDOUT and STDERR to a log file, shared by every run of the command if it's restarted
+	job.logFile, err = os.OpenFile(logPath(job.ID), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open job's log file: %w", err)
+	}
+	cmd, err := job.launch()
+	if err != nil {
+		return err
+	}
+	job.Lock()
+	job.started = time.Now()
+	job.Unlock()
+	job.publish(EventStarted)
+	// Run go routine to handle the blocking call exec.Cmd.Wait(), once started is published so it's always first
+	go job.wait(cmd)
+	if job.opts.Timeout > 0 {
+		go job.stopAfter(job.opts.Timeout)
+	}
+	return nil
+}
+
+// abort completes a job that failed to start, removing it's log file and cgroup
+func (job *Job) abort() {
+	job.Lock()
+	job.running = false
+	// A job stopped while it was starting keeps the exit reason Stopped
+	if job.reason == "" {
+		job.reason = StartFailed
+	}
+	job.finished = time.Now()
+	job.Unlock()
+	if job.logFile != nil {
+		job.logFile.Close()
+	}
+	job.cleanup()
+	close(job.done)
+	job.publishCompleted()
+}
+
+// launch adds the job's command to it's cgroup and starts the exec.Cmd. The caller must start a go routine to handle
+// the blocking call to Wait so that we can update the job's running flag or restart the command.
+func (job *Job) launch() (*exec.Cmd, error) {
+	// Each run needs a new exec.Cmd since one can only be started once, and it's only swapped into the job once
+	// started so Status doesn't read it while it's starting
+	job.RLock()
+	cmd := &exec.Cmd{Path: job.path, Args: slices.Clone(job.args), Dir: job.cmd.Dir, Err: job.cmd.Err}
+	reason := job.reason
+	job.RUnlock()
+	// Don't launch a job that's been stopped, it's restarts are disabled and Stop may be deleting it's cgroup
+	if reason != "" {
+		return nil, fmt.Errorf("job %s was %s before it's command was launched", job.ID, reason)
 	}
 	// Add job's process to cgroup
-	if err = j.con.AddProcess(j.ID, j.cmd); err != nil {
+	if err := job.con.AddProcess(job.ID, cmd); err != nil {
 		return nil, fmt.Errorf("failed to add PID to cgroup: %w", err)
 	}
-	defer syscall.Close(j.cmd.SysProcAttr.CgroupFD)
+	defer syscall.Close(cmd.SysProcAttr.CgroupFD)
 
-	// Don't inherit environment from parent
-	j.cmd.Env = []string{}
-	// Pipe STDOUT and STDERR to a log file
-	f, err := os.OpenFile(logPath(j.ID), os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open job's log file: %w", err)
-	}
-	j.cmd.Stdout = f
-	j.cmd.Stderr = f
+	// Don't inherit environment from parent, only the job's own
+	cmd.Env = slices.Clone(job.env)
+	cmd.Stdout = job.logFile
+	cmd.Stderr = job.logFile
 
-	// Run the command as a given user as not to escalate privilege, since the executing user must also manage cgroups
-	if WORKER_UID != -1 && WORKER_GID != -1 {
-		j.cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(WORKER_UID), Gid: uint32(WORKER_GID)}
+	// Run the command as a given user as not to escalate privilege, since the executing user must also manage cgroups.
+	// Only root can switch user, otherwise the job runs as the unprivileged executing user
+	if WORKER_UID != -1 && WORKER_GID != -1 && os.Geteuid() == 0 {
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(WORKER_UID), Gid: uint32(WORKER_GID)}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	// Hold the process once it has exec'd the job's command by tracing it, only the thread that started it can release it
+	enroller, enrolls := job.con.(ProcessEnroller)
+	if enrolls {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		cmd.SysProcAttr.Ptrace = true
 	}
-	j.cmd.SysProcAttr.Setpgid = true
 
 	// Start the job
-	err = j.cmd.Start()
-	if err != nil {
+	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("failed to start job's exec.Cmd: %w", err)
 	}
+	if enrolls {
+		if err := enroll(enroller, job.ID, cmd.Process.Pid); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, fmt.Errorf("failed to enroll job's process: %w", err)
+		}
+	}
 	// Assign the process group ID to the job so that we have a reference to signal child processes in Stop if the command quits
-	j.pgid, err = syscall.Getpgid(j.cmd.Process.Pid)
+	pgid, err := syscall.Getpgid(cmd.Process.Pid)
 	if err != nil {
-		fmt.Printf("error getting pgid: %v", err)
-		return nil, err
+		// Nothing could signal the process without it's group, so it's killed rather than left running
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("failed to get job's process group: %w", err)
 	}
-	// Run go routine to handle the blocking call exec.Cmd.Wait() and update the running flag to indicate the job has complete
-	go func(runningJob *Job, logFile *os.File) {
-		runningJob.cmd.Wait()
-		runningJob.setRunning(false)
-		runningJob.done <- true
-		logFile.Close()
-		// Close all of the readers reading the logs
-		runningJob.Lock()
-		for _, r := range runningJob.readers {
-			r.Close()
-		}
-		runningJob.Unlock()
-	}(j, f)
-	return j, nil
+	job.Lock()
+	job.cmd = cmd
+	job.pgid = pgid
+	stopped := job.reason != ""
+	job.Unlock()
+	// The job may have been stopped while restarting, after Stop signalled the previous process group
+	if stopped {
+		syscall.Kill(-pgid, syscall.SIGTERM)
+	}
+	return cmd, nil
 }
 
-// Stop request a job's termination using SIGTERM and deletes it's cgroup. If the SIGTERM is ignored, we send
-// a SIGKILL after STOP_GRACE_PERIOD.
-func (job *Job) Stop(ctx context.Context) error {
-	// Regardless of signalling errors, ensure we clean up the job's log file and cgroup
-	defer func() {
-		os.Remove(logPath(job.ID))
-		job.con.DeleteGroup(job.ID)
-	}()
-	// Send SIGTERM to process group
-	if err := syscall.Kill(-job.pgid, syscall.SIGTERM); err != nil {
+// enroll waits for a traced process to stop before it runs the job's command, then enrolls it with the ProcessEnroller
+// and releases it to run the command
+func enroll(enroller ProcessEnroller, name string, pid int) error {
+	var status syscall.WaitStatus
+	if _, err := syscall.Wait4(pid, &status, 0, nil); err != nil {
 		return err
 	}
-	// Set up timeout
-	killCtx, cancel := context.WithTimeout(context.Background(), STOP_GRACE_PERIOD)
-	defer cancel()
-	// If job is running potentially wait on either done channel, SIGKILL after timeout or caller's context timeout
-	if job.isRunning() {
+	if !status.Stopped() {
+		return fmt.Errorf("process %d exited before it could be enrolled", pid)
+	}
+	if err := enroller.EnrollProcess(name, pid); err != nil {
+		return err
+	}
+	return syscall.PtraceDetach(pid)
+}
+
+// wait blocks until the command exits, then either restarts it according to the job's RestartPolicy or updates the
+// running flag to indicate the job has complete
+func (job *Job) wait(cmd *exec.Cmd) {
+	cmd.Wait()
+	// Once the command is reaped it's process group ID can be reused, so it's no longer signalled. Anything left in the
+	// group is still killed with the job's cgroup
+	job.Lock()
+	job.pgid = 0
+	job.Unlock()
+	job.checkOOM()
+	failures := 0
+	for job.shouldRestart(cmd.ProcessState.ExitCode()) {
+		// Wait for the backoff, unless the job is stopped in the meantime
+		timer := time.NewTimer(restartBackoff(job.restartCount()))
 		select {
-		case <-job.done:
-			break
-		case <-killCtx.Done():
-			if err := syscall.Kill(-job.pgid, syscall.SIGKILL); err != nil {
-				return err
+		case <-timer.C:
+		case <-job.stopping:
+			timer.Stop()
+			job.finish()
+			return
+		}
+		// Kill anything the previous run left in the job's cgroup
+		if err := job.con.KillGroup(job.ID); err != nil {
+			fmt.Printf("error killing job %s before restart: %v\n", job.ID, err)
+		}
+		restarts := job.recordRestart(cmd.ProcessState.ExitCode())
+		fmt.Fprintf(job.logFile, "--- restart %d, previous run exited with code %d ---\n", restarts, cmd.ProcessState.ExitCode())
+		next, err := job.launch()
+		if err != nil {
+			// Retry after the backoff like any other restart, until the command fails to launch too many times in a row
+			fmt.Printf("error restarting job %s: %v\n", job.ID, err)
+			fmt.Fprintf(job.logFile, "--- restart %d failed: %v ---\n", restarts, err)
+			if failures++; failures >= RESTART_MAX_LAUNCH_FAILURES {
+				job.setExitReason(StartFailed)
+				break
 			}
-		case <-ctx.Done():
-			return ctx.Err()
+			continue
+		}
+		job.publish(EventRestarted)
+		go job.wait(next)
+		return
+	}
+	job.finish()
+}
+
+// shouldRestart decides if the command should be run again given it's exit code, the job's RestartPolicy, it's
+// MaxRetries and if the job has been stopped
+func (job *Job) shouldRestart(exitCode int) bool {
+	job.RLock()
+	defer job.RUnlock()
+	if job.reason != "" || (job.opts.MaxRetries > 0 && job.restarts >= job.opts.MaxRetries) {
+		return false
+	}
+	switch job.opts.RestartPolicy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0
+	default:
+		return false
+	}
+}
+
+// restartBackoff returns the delay before a command is restarted, doubling from RESTART_BACKOFF with each restart up
+// to RESTART_BACKOFF_MAX
+func restartBackoff(restarts int32) time.Duration {
+	backoff := RESTART_BACKOFF
+	for i := int32(0); i < restarts && backoff < RESTART_BACKOFF_MAX; i++ {
+		backoff *= 2
+	}
+	return min(backoff, RESTART_BACKOFF_MAX)
+}
+
+// recordRestart increments the job's restart count and stores the exit code of the previous run, returning the count
+func (job *Job) recordRestart(exitCode int) int32 {
+	job.Lock()
+	defer job.Unlock()
+	job.restarts++
+	job.lastExit = int32(exitCode)
+	return job.restarts
+}
+
+func (job *Job) restartCount() int32 {
+	job.RLock()
+	defer job.RUnlock()
+	return job.restarts
+}
+
+// finish kills anything the command left in the job's cgroup, i.e. descendants that called setsid, then updates the
+// running flag to indicate the job has complete and closes the job's log file and all of the readers reading the logs
+func (job *Job) finish() {
+	if err := job.con.KillGroup(job.ID); err != nil {
+		fmt.Printf("error killing job %s after it exited: %v\n", job.ID, err)
+	}
+	job.Lock()
+	job.running = false
+	job.finished = time.Now()
+	job.Unlock()
+	close(job.done)
+	job.publishCompleted()
+	job.logFile.Close()
+	job.Lock()
+	for _, r := range job.readers {
+		r.Close()
+	}
+	job.Unlock()
+}
+
+// StopStep is a signal sent to a job's process group while stopping it, followed by how long to wait for the job to
+// exit before the next step
+type StopStep struct {
+	Signal syscall.Signal
+	Wait   time.Duration
+}
+
+// GracefulStop returns the escalation that sends SIGTERM, then SIGKILL if the job is still running after the grace period
+func GracefulStop(grace time.Duration) []StopStep {
+	return []StopStep{{Signal: syscall.SIGTERM, Wait: grace}, {Signal: syscall.SIGKILL}}
+}
+
+// Stop stops the job with SIGTERM then SIGKILL after STOP_GRACE_PERIOD, see StopWith
+func (job *Job) Stop(ctx context.Context) error {
+	return job.StopWith(ctx, GracefulStop(STOP_GRACE_PERIOD))
+}
+
+// StopWith stops the job using StopAsync and waits until it has been stopped and cleaned up. If ctx is done first it's
+// error is returned, but the job continues to be stopped in the background.
+func (job *Job) StopWith(ctx context.Context, steps []StopStep) error {
+	if err := job.StopAsync(steps); err != nil {
+		return err
+	}
+	select {
+	case <-job.stopped:
+		job.RLock()
+		defer job.RUnlock()
+		return job.stopErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StopAsync begins stopping the job in a go routine and returns straight away, the job's state is JobStopping until
+// it has stopped. Each step's signal is sent to the job's process group in turn until the job exits, then anything
+// left in the job's cgroup is killed, so descendants that escaped the process group (i.e. by calling setsid) do not
+// survive the job. Finally it's cgroup is deleted, while it's log file is kept so the output can still be read until
+// the job is deleted. Stopping a job disables any further restarts. A job that's already stopping continues with it's
+// original steps.
+func (job *Job) StopAsync(steps []StopStep) error {
+	for _, step := range steps {
+		if step.Wait < 0 {
+			return fmt.Errorf("stop step %s wait %s not valid", step.Signal, step.Wait)
 		}
 	}
+	job.stopOnce.Do(func() {
+		// A pending job is cancelled without ever creating it's cgroup, whether it's queued, waiting for the jobs it
+		// depends on or hasn't been submitted yet, e.g. while it's being handed to Start
+		if job.cancel() || job.completePending(Stopped) {
+			close(job.stopped)
+			return
+		}
+		running := job.isRunning()
+		job.setExitReason(Stopped)
+		close(job.stopping)
+		go func() {
+			err := job.escalate(steps, running)
+			if err != nil {
+				fmt.Printf("error stopping job %s: %v\n", job.ID, err)
+			}
+			job.Lock()
+			job.stopErr = err
+			job.Unlock()
+			close(job.stopped)
+		}()
+	})
 	return nil
 }
 
+// escalate sends each step's signal until the job exits, then kills anything left in the job's cgroup. The job's
+// process group may have exited already, i.e. the job completed by itself or is waiting to restart, which isn't an
+// error since descendants that escaped it are still killed with the cgroup
+func (job *Job) escalate(steps []StopStep, running bool) error {
+	// Regardless of signalling errors, ensure we release the job's cgroup
+	defer job.releaseGroup()
+	var err error
+	for _, step := range steps {
+		if err = job.signal(step.Signal); err == syscall.ESRCH {
+			err = nil
+			break
+		} else if err != nil {
+			break
+		}
+		if !job.isRunning() || job.exitsWithin(step.Wait) {
+			break
+		}
+	}
+	// Kill anything left in the job's cgroup and give the job time to exit before it's cgroup is deleted
+	if killErr := job.con.KillGroup(job.ID); err == nil {
+		err = killErr
+	}
+	if running {
+		job.exitsWithin(CGROUP_DELETE_TIMEOUT)
+	}
+	return err
+}
+
+// Wait blocks until the job has completed, including jobs that were stopped, cancelled or failed to start, and returns
+// it's final status. If ctx is done first it's error is returned. Any number of callers can wait on the same job
+func (job *Job) Wait(ctx context.Context) (JobStatus, error) {
+	select {
+	case <-job.done:
+		return job.Status(), nil
+	case <-ctx.Done():
+		return JobStatus{}, ctx.Err()
+	}
+}
+
+// exitsWithin returns true if the job completes before the timeout
+func (job *Job) exitsWithin(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-job.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
 // Status generates a JobStatus with information from the job and it's underlying os.Process & os.ProcessState
 func (job *Job) Status() JobStatus {
+	job.RLock()
+	cmd, pending, queue, waiting, reason, deleted := job.cmd, job.pending, job.queue, job.waiting, job.reason, job.deleted
+	status := JobStatus{
+		ID:           job.ID,
+		Owner:        job.owner,
+		Name:         job.metadata.Name,
+		Command:      job.path,
+		Args:         []string{},
+		Opts:         job.opts,
+		Created:      job.created,
+		Started:      job.started,
+		Finished:     job.finished,
+		Restarts:     job.restarts,
+		LastExitCode: job.lastExit,
+		Capabilities: job.caps,
+		Labels:       maps.Clone(job.metadata.Labels),
+		Annotations:  maps.Clone(job.metadata.Annotations),
+	}
+	// Report the command as it was given rather than the path it was resolved to
+	if len(job.args) > 0 {
+		status.Command, status.Args = job.args[0], slices.Clone(job.args[1:])
+	}
+	job.RUnlock()
+	if !status.Started.IsZero() {
+		if status.Finished.IsZero() {
+			status.Duration = time.Since(status.Started)
+		} else {
+			status.Duration = status.Finished.Sub(status.Started)
+		}
+	}
+	if pending {
+		status.State = JobPending
+		status.Pending = waiting
+		status.Queued = queue != nil
+		if queue != nil {
+			status.QueuePosition = int32(queue.position(job))
+		}
+		return status
+	}
 	// Get PID and possible exit code from Process and ProcessState, assume running if ProcessState is nil
-	pid := 0
-	if job.cmd.Process != nil {
-		pid = job.cmd.Process.Pid
+	if cmd.Process != nil {
+		status.PID = int64(cmd.Process.Pid)
 	}
 	// Check if running flag has been set after blocking Wait call on job.cmd
-	running := job.isRunning()
-	exitCode := 0
-	if !running {
-		exitCode = job.cmd.ProcessState.ExitCode()
+	status.Running = job.isRunning()
+	if status.Running {
+		status.State = JobRunning
+		if reason != "" {
+			status.State = JobStopping
+		}
+		return status
 	}
-	return JobStatus{
-		ID:       job.ID,
-		PID:      int64(pid),
-		Running:  running,
-		ExitCode: int32(exitCode),
+	if cmd.ProcessState != nil {
+		status.ExitCode = int32(cmd.ProcessState.ExitCode())
 	}
+	status.ExitReason = job.exitReason()
+	// Only jobs that launched their command have logs, those that were cancelled or failed to start have none to keep
+	status.Retained = !deleted && !status.Started.IsZero()
+	switch {
+	case status.ExitReason == Stopped || status.ExitReason == Skipped:
+		status.State = JobStopped
+	case status.ExitReason == Exited && status.ExitCode == 0:
+		status.State = JobExited
+	default:
+		status.State = JobFailed
+	}
+	return status
+}
+
+// UpdateResources changes the resource controls of a running job's cgroup to the CPU, IO, memory and cpuset options in
+// opts, the job's other options are unchanged. EventResourcesUpdated is published once the cgroup has been updated
+func (job *Job) UpdateResources(opts JobOpts) error {
+	job.Lock()
+	if !job.running || job.reason != "" {
+		job.Unlock()
+		return ErrJobNotRunning
+	}
+	updated := job.opts
+	updated.CPUWeight, updated.IOWeight, updated.MemLimit = opts.CPUWeight, opts.IOWeight, opts.MemLimit
+	updated.CPUMax, updated.CPUSet = opts.CPUMax, opts.CPUSet
+	if err := job.con.AddResourceControl(job.ID, updated); err != nil {
+		job.Unlock()
+		return fmt.Errorf("failed to update resource control: %w", err)
+	}
+	job.opts = updated
+	if reporter, ok := job.con.(CapabilityReporter); ok {
+		job.caps = reporter.Capabilities(updated)
+	}
+	job.Unlock()
+	job.publish(EventResourcesUpdated)
+	return nil
+}
+
+// SetOwner records the owner of the job, i.e. the common name of the client that started it, to be reported in it's
+// status
+func (job *Job) SetOwner(owner string) {
+	job.Lock()
+	defer job.Unlock()
+	job.owner = owner
+}
+
+// SetEnv sets the environment variables of the job's command as key=value pairs, it must be called before the job is
+// started. The command doesn't inherit the job worker's environment
+func (job *Job) SetEnv(env []string) {
+	job.Lock()
+	defer job.Unlock()
+	job.env = slices.Clone(env)
 }
 
 // Output returns a wrapped io.ReadCloser that "tails" the job's log file
 // If mode=FollowLogs, the Read will block and poll for updates to the file. The Read will block until either
 // the job completes, or Close() is called
 // If mode=DontFollowLogs, upon Read'ing the entire file an io.EOF will be returned
+// The readers of a completed job have already been closed, so it's logs are read to the end without following
 func (job *Job) Output(mode OutputMode) (reader io.ReadCloser, err error) {
+	job.Lock()
+	defer job.Unlock()
+	done := job.isDone()
+	if done {
+		mode = DontFollowLogs
+	}
 	reader, err = newTailReader(logPath(job.ID), TAIL_POLL_INTERVAL, mode)
 	if err != nil {
 		return nil, err
 	}
+	if !done {
+		job.readers = append(job.readers, reader)
+	}
+	return reader, nil
+}
+
+// Delete removes a completed job's log file and cgroup, after which it's logs can no longer be read. A job that's
+// being stopped finishes cleaning up first. Returns ErrJobNotCompleted if the job is pending or running
+func (job *Job) Delete() error {
+	if !job.isDone() {
+		return ErrJobNotCompleted
+	}
+	select {
+	case <-job.stopping:
+		<-job.stopped
+	default:
+	}
 	job.Lock()
-	job.readers = append(job.readers, reader)
+	job.deleted = true
 	job.Unlock()
-	return reader, nil
+	job.cleanup()
+	return nil
 }
 
-func (job *Job) setRunning(running bool) {
+// cleanup kills anything left in the job's cgroup and removes it's log file and cgroup, recording any that could not be
+// removed as a Leak
+func (job *Job) cleanup() {
+	// A failed kill isn't reported since the cgroup can't be deleted while it's populated
+	job.con.KillGroup(job.ID)
+	if err := os.Remove(logPath(job.ID)); err != nil && !os.IsNotExist(err) {
+		recordLeak(LeakedLogFile, job.ID, logPath(job.ID), err)
+	} else {
+		clearLeak(LeakedLogFile, logPath(job.ID))
+	}
+	job.releaseGroup()
+}
+
+// releaseGroup deletes the job's cgroup, recording it as a Leak if it could not be removed or clearing it's Leak if
+// it failed to be removed before
+func (job *Job) releaseGroup() {
+	if err := job.con.DeleteGroup(job.ID); err != nil {
+		recordLeak(LeakedCgroup, job.ID, job.ID, err)
+	} else {
+		clearLeak(LeakedCgroup, job.ID)
+	}
+}
+
+// stopAfter stops the job once it has been running for the timeout, unless it has already exited
+func (job *Job) stopAfter(timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-job.done:
+	case <-timer.C:
+		job.setExitReason(TimedOut)
+		if err := job.Stop(context.Background()); err != nil {
+			fmt.Printf("error stopping timed out job %s: %v\n", job.ID, err)
+		}
+	}
+}
+
+// signal sends a signal to the job's process group
+func (job *Job) signal(sig syscall.Signal) error {
+	job.RLock()
+	pgid := job.pgid
+	job.RUnlock()
+	// A job that never launched or whose command has exited has no process group, and signalling 0 would signal our own
+	if pgid == 0 {
+		return syscall.ESRCH
+	}
+	return syscall.Kill(-pgid, sig)
+}
+
+// cancel removes a pending job from it's Queue, or stops it waiting for it's dependencies, and completes it with the
+// exit reason Stopped. Returns false if the job is not waiting
+func (job *Job) cancel() bool {
+	job.Lock()
+	queue, waiting := job.queue, job.waiting
+	job.waiting = false
+	job.Unlock()
+	if waiting {
+		job.completePending(Stopped)
+		return true
+	}
+	if queue == nil || !queue.remove(job) {
+		return false
+	}
+	job.completePending(Stopped)
+	return true
+}
+
+// completePending completes a job that was never started, i.e. cancelled or rejected by admission control when
+// leaving a Queue. Returns false if the job has already been started or completed
+func (job *Job) completePending(reason ExitReason) bool {
+	job.Lock()
+	if !job.pending {
+		job.Unlock()
+		return false
+	}
+	job.pending = false
+	job.reason = reason
+	job.finished = time.Now()
+	job.Unlock()
+	close(job.done)
+	job.publishCompleted()
+	return true
+}
+
+// isDone returns true once the job has completed, including jobs that were cancelled or failed to start
+func (job *Job) isDone() bool {
+	select {
+	case <-job.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// setExitReason records why the job is being terminated, keeping the first reason if the job is terminated again
+func (job *Job) setExitReason(reason ExitReason) {
 	job.Lock()
 	defer job.Unlock()
-	job.running = running
+	if job.running && job.reason == "" {
+		job.reason = reason
+	}
+}
+
+// exitReason returns why the job terminated, a job that wasn't stopped or timed out exited by itself
+func (job *Job) exitReason() ExitReason {
+	job.RLock()
+	defer job.RUnlock()
+	if job.reason == "" {
+		return Exited
+	}
+	return job.reason
 }
 
 func (job *Job) isRunning() bool {
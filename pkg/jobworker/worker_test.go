@@ -3,8 +3,15 @@ package jobworker
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -21,6 +28,7 @@ func (con *mockController) AddProcess(name string, cmd *exec.Cmd) error {
 func (con *mockController) CreateGroup(name string) error                      { return nil }
 func (con *mockController) DeleteGroup(name string) error                      { return nil }
 func (con *mockController) AddResourceControl(name string, opts JobOpts) error { return nil }
+func (con *mockController) KillGroup(name string) error                        { return nil }
 
 func mockUserId() {
 	WORKER_UID = -1
@@ -31,7 +39,7 @@ func TestJobWorker_Can_Start_A_Job_And_Read_Logs(t *testing.T) {
 	mockUserId()
 	// Define job with known output to assert later
 	args := []string{"-c", fmt.Sprintf("for run in {1..%d}; do echo ${run}: %s; sleep 0.01; done", n, echo)}
-	opts := JobOpts{100, 100, 50 * CgroupMB}
+	opts := JobOpts{CPUWeight: 100, IOWeight: 100, MemLimit: 50 * CgroupMB}
 
 	// Run the job
 	job, err := StartWithController(&mockController{}, opts, cmd, args...)
@@ -65,11 +73,43 @@ func TestJobWorker_Can_Start_A_Job_And_Read_Logs(t *testing.T) {
 	}
 }
 
+func TestJobWorker_Following_Completed_Jobs_Logs_Ends(t *testing.T) {
+	mockUserId()
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, "-c", "echo hello")
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = job.Wait(ctx); err != nil {
+		t.Fatal("expected job to complete: ", err)
+	}
+	reader, err := job.Output(FollowLogs)
+	if err != nil {
+		t.Fatal("could not get reader for job's output")
+	}
+	defer reader.Close()
+	// Assert the reader ends at the end of the logs rather than waiting for a job that has already completed
+	read := make(chan string, 1)
+	go func() {
+		logs, _ := io.ReadAll(reader)
+		read <- string(logs)
+	}()
+	select {
+	case logs := <-read:
+		if logs != "hello\n" {
+			t.Errorf("expected completed job's logs, actual %q", logs)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected following a completed job's logs to end")
+	}
+}
+
 func TestJobWorker_Can_Stop_Long_Running_Job(t *testing.T) {
 	mockUserId()
 	// Define infinite task
 	args := []string{"-c", "while true; do sleep 2; done"}
-	opts := JobOpts{100, 100, 50 * CgroupMB}
+	opts := JobOpts{CPUWeight: 100, IOWeight: 100, MemLimit: 50 * CgroupMB}
 
 	// Run the job
 	job, err := StartWithController(&mockController{}, opts, cmd, args...)
@@ -89,13 +129,144 @@ func TestJobWorker_Can_Stop_Long_Running_Job(t *testing.T) {
 	if err = job.Stop(ctx); err != nil {
 		t.Errorf("expected to be able to stop the job, error : %v", err)
 	}
+	if reason := job.Status().ExitReason; reason != Stopped {
+		t.Errorf("expected exit reason to be %s, actual %s", Stopped, reason)
+	}
+}
+
+func TestJobWorker_Stop_Escalates_Signals(t *testing.T) {
+	mockUserId()
+	// Define a task that ignores SIGINT and SIGTERM, recording them to a file to assert the order they were received in
+	trapped := filepath.Join(t.TempDir(), "trapped")
+	script := fmt.Sprintf("trap 'echo int >> %[1]s' INT; trap 'echo term >> %[1]s' TERM; while true; do sleep 0.1; done", trapped)
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, "-c", script)
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	// Give bash time to install the traps
+	time.Sleep(100 * time.Millisecond)
+	steps := []StopStep{
+		{Signal: syscall.SIGINT, Wait: 200 * time.Millisecond},
+		{Signal: syscall.SIGTERM, Wait: 200 * time.Millisecond},
+		{Signal: syscall.SIGKILL},
+	}
+	if err = job.StopAsync(steps); err != nil {
+		t.Fatalf("expected to be able to stop the job, error : %v", err)
+	}
+	// Assert the job is stopping rather than blocking until it has stopped
+	if state := job.Status().State; state != JobStopping {
+		t.Errorf("expected state to be %s, actual %s", JobStopping, state)
+	}
+	// Assert each signal was sent in turn before the job was killed
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = job.StopWith(ctx, nil); err != nil {
+		t.Errorf("expected to wait for the job to stop, error : %v", err)
+	}
+	b, err := os.ReadFile(trapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Fields(string(b)); !slices.Equal(lines, []string{"int", "term"}) {
+		t.Errorf("expected job to receive SIGINT then SIGTERM, actual %v", lines)
+	}
+	if state := job.Status().State; state != JobStopped {
+		t.Errorf("expected state to be %s, actual %s", JobStopped, state)
+	}
+}
+
+func TestJobWorker_Stop_Continues_After_Context_Is_Done(t *testing.T) {
+	mockUserId()
+	args := []string{"-c", "trap '' TERM; while true; do sleep 0.1; done"}
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, args...)
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	// Assert the caller's context ending does not abandon the job
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err = job.StopWith(ctx, GracefulStop(200*time.Millisecond)); err != context.DeadlineExceeded {
+		t.Errorf("expected the caller's context to be done, actual %v", err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if status := job.Status(); status.State != JobStopped {
+		t.Errorf("expected job to be stopped in the background, actual %+v", status)
+	}
+}
+
+func TestJobWorker_Stop_Before_Start_Completes_Job(t *testing.T) {
+	mockUserId()
+	job := NewJobWithController(&mockController{}, JobOpts{}, cmd, "-c", "while true; do sleep 0.1; done")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := job.Stop(ctx); err != nil {
+		t.Fatalf("expected to be able to stop the pending job, error : %v", err)
+	}
+	if status := job.Status(); status.State != JobStopped || status.ExitReason != Stopped {
+		t.Errorf("expected job to be stopped, actual %+v", status)
+	}
+	// Assert a stopped job can't be started, since nothing would be left to stop it
+	if err := job.Start(); err == nil {
+		t.Error("expected starting a stopped job to fail")
+	}
+	if status := job.Status(); status.Running {
+		t.Errorf("expected job not to be running, actual %+v", status)
+	}
+}
+
+func TestJobWorker_Stops_Job_After_Timeout(t *testing.T) {
+	mockUserId()
+	args := []string{"-c", "while true; do sleep 2; done"}
+	opts := JobOpts{CPUWeight: 100, IOWeight: 100, MemLimit: 50 * CgroupMB, Timeout: 50 * time.Millisecond}
+
+	// Run the job
+	job, err := StartWithController(&mockController{}, opts, cmd, args...)
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	// Assert the job was stopped once the timeout passed
+	status := job.Status()
+	if status.Running {
+		t.Fatal("expected job to be stopped after it's timeout and it is running")
+	}
+	if status.ExitReason != TimedOut {
+		t.Errorf("expected exit reason to be %s, actual %s", TimedOut, status.ExitReason)
+	}
+}
+
+func TestRecordLeak_Keeps_Most_Recent_Leaks(t *testing.T) {
+	MAX_LEAKS = 2
+	defer func() { MAX_LEAKS = 1000 }()
+	leaks.Lock()
+	saved := leaks.leaks
+	leaks.leaks = []Leak{}
+	leaks.Unlock()
+	defer func() {
+		leaks.Lock()
+		leaks.leaks = saved
+		leaks.Unlock()
+	}()
+	for _, id := range []string{"a", "b", "b", "c"} {
+		recordLeak(LeakedCgroup, id, id, ErrCgroupPopulated)
+	}
+	ids := []string{}
+	for _, leak := range Leaks() {
+		ids = append(ids, leak.JobID)
+	}
+	if expected := []string{"b", "c"}; !slices.Equal(ids, expected) {
+		t.Errorf("expected leaks %v, actual %v", expected, ids)
+	}
 }
 
 func TestJobWorker_Check_Status_After_Job_Completes(t *testing.T) {
 	mockUserId()
 	// Define job that completes quickly
 	args := []string{"-c", "echo hello world"}
-	opts := JobOpts{100, 100, 50 * CgroupMB}
+	opts := JobOpts{CPUWeight: 100, IOWeight: 100, MemLimit: 50 * CgroupMB}
 
 	// Run the job
 	job, err := StartWithController(&mockController{}, opts, cmd, args...)
@@ -115,12 +286,30 @@ func TestJobWorker_Check_Status_After_Job_Completes(t *testing.T) {
 	if status.ExitCode != 0 {
 		t.Errorf("expected exit code to be 0 and was %d", status.ExitCode)
 	}
+	if status.ExitReason != Exited {
+		t.Errorf("expected exit reason to be %s, actual %s", Exited, status.ExitReason)
+	}
+}
+
+func TestJobWorker_Exited_Job_Has_No_Process_Group(t *testing.T) {
+	mockUserId()
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, "-c", "exit 0")
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	job.Wait(ctx)
+	// Assert the exited command's process group, whose ID may be reused, is no longer signalled
+	if err = job.signal(syscall.SIGTERM); err != syscall.ESRCH {
+		t.Errorf("expected signalling an exited job to return ESRCH, actual %v", err)
+	}
 }
 
 func TestJobWorker_Check_Exit_Code_Is_Propagated(t *testing.T) {
 	mockUserId()
 	args := []string{"-c", "exit 4"}
-	opts := JobOpts{100, 100, 50 * CgroupMB}
+	opts := JobOpts{CPUWeight: 100, IOWeight: 100, MemLimit: 50 * CgroupMB}
 
 	// Run the job
 	job, err := StartWithController(&mockController{}, opts, cmd, args...)
@@ -143,6 +332,50 @@ func TestJobWorker_Check_Exit_Code_Is_Propagated(t *testing.T) {
 	}
 }
 
+func TestJobWorker_Status_Reports_Metadata_And_State(t *testing.T) {
+	mockUserId()
+	tests := map[string]struct {
+		args  []string
+		stop  bool
+		state JobState
+	}{
+		"exited":  {args: []string{"-c", "exit 0"}, state: JobExited},
+		"failed":  {args: []string{"-c", "exit 4"}, state: JobFailed},
+		"stopped": {args: []string{"-c", "sleep 30"}, stop: true, state: JobStopped},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := JobOpts{CPUWeight: 100, IOWeight: 100, MemLimit: 50 * CgroupMB}
+			job := NewJobWithController(&mockController{}, opts, cmd, tt.args...)
+			job.SetOwner("alice")
+			if status := job.Status(); status.State != JobPending || !status.Started.IsZero() {
+				t.Errorf("expected job to be pending until started, actual %+v", status)
+			}
+			if err := job.Start(); err != nil {
+				t.Fatal("failed to start job: ", err)
+			}
+			if tt.stop {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				if err := job.Stop(ctx); err != nil {
+					t.Errorf("expected to be able to stop the job, error : %v", err)
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			status := job.Status()
+			if status.State != tt.state {
+				t.Errorf("expected state to be %s, actual %s", tt.state, status.State)
+			}
+			if status.Owner != "alice" || status.Command != cmd || !slices.Equal(status.Args, tt.args) || status.Opts != opts {
+				t.Errorf("expected status to report the job's owner, command and options, actual %+v", status)
+			}
+			if status.Created.After(status.Started) || status.Started.After(status.Finished) || status.Duration != status.Finished.Sub(status.Started) {
+				t.Errorf("expected status to report when the job was created, started and finished, actual %+v", status)
+			}
+		})
+	}
+}
+
 func TestParseCgroupByte(t *testing.T) {
 	// test B
 	b, err := ParseCgroupByte("100")
@@ -185,3 +418,163 @@ func TestParseCgroupByte(t *testing.T) {
 		return
 	}
 }
+
+func TestJobWorker_Wait_Returns_Final_Status_To_Every_Caller(t *testing.T) {
+	mockUserId()
+	job, err := StartWithController(&mockController{}, JobOpts{}, cmd, "-c", "sleep 0.2; exit 3")
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	// Assert waiting is bounded by the context
+	short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = job.Wait(short); err != context.DeadlineExceeded {
+		t.Errorf("expected wait to return the context's error, actual %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, err := job.Wait(ctx)
+			if err != nil || status.State != JobFailed || status.ExitCode != 3 || status.ExitReason != Exited {
+				t.Errorf("expected every caller to get the job's final status, actual %+v, error %v", status, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestJobWorker_Restarts_Job_On_Failure(t *testing.T) {
+	mockUserId()
+	RESTART_BACKOFF = 10 * time.Millisecond
+	defer func() { RESTART_BACKOFF = time.Second }()
+	args := []string{"-c", "echo run; exit 3"}
+	opts := JobOpts{CPUWeight: 100, IOWeight: 100, RestartPolicy: RestartOnFailure, MaxRetries: 2}
+
+	// Run the job
+	job, err := StartWithController(&mockController{}, opts, cmd, args...)
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	// Assert the job was restarted until it reached max retries
+	status := job.Status()
+	if status.Running {
+		t.Fatal("expected job not to be running after max retries and it is")
+	}
+	if status.Restarts != 2 || status.LastExitCode != 3 || status.ExitCode != 3 {
+		t.Errorf("expected 2 restarts with exit code 3, actual %d restarts with exit code %d", status.Restarts, status.LastExitCode)
+	}
+	// Assert every run appended to the same log with a restart marker
+	reader, err := job.Output(DontFollowLogs)
+	if err != nil {
+		t.Fatal("could not get reader for job's output")
+	}
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	logs := []string{}
+	for scanner.Scan() {
+		logs = append(logs, scanner.Text())
+	}
+	expected := []string{
+		"run",
+		"--- restart 1, previous run exited with code 3 ---",
+		"run",
+		"--- restart 2, previous run exited with code 3 ---",
+		"run",
+	}
+	if !slices.Equal(logs, expected) {
+		t.Errorf("expected logs %v, actual %v", expected, logs)
+	}
+}
+
+func TestJobWorker_Stop_Disables_Restarts(t *testing.T) {
+	mockUserId()
+	RESTART_BACKOFF = 10 * time.Millisecond
+	defer func() { RESTART_BACKOFF = time.Second }()
+	args := []string{"-c", "sleep 0.05"}
+	opts := JobOpts{CPUWeight: 100, IOWeight: 100, RestartPolicy: RestartAlways}
+
+	// Run the job
+	job, err := StartWithController(&mockController{}, opts, cmd, args...)
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	// Stop the job and assert it's not restarted again
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = job.Stop(ctx); err != nil {
+		t.Errorf("expected to be able to stop the job, error : %v", err)
+	}
+	restarts := job.Status().Restarts
+	if restarts == 0 {
+		t.Error("expected job to have been restarted before it was stopped")
+	}
+	time.Sleep(100 * time.Millisecond)
+	status := job.Status()
+	if status.Running || status.Restarts != restarts {
+		t.Errorf("expected job to be stopped without restarting, actual running %t with %d restarts", status.Running, status.Restarts)
+	}
+}
+
+// relaunchController fails to add every process to the job's cgroup after the first
+type relaunchController struct {
+	mockController
+	sync.Mutex
+	launches int
+}
+
+func (con *relaunchController) AddProcess(name string, cmd *exec.Cmd) error {
+	con.Lock()
+	defer con.Unlock()
+	if con.launches++; con.launches > 1 {
+		return errors.New("cgroup is gone")
+	}
+	return con.mockController.AddProcess(name, cmd)
+}
+
+func TestJobWorker_Fails_Job_That_Cant_Be_Relaunched(t *testing.T) {
+	mockUserId()
+	RESTART_BACKOFF = 10 * time.Millisecond
+	defer func() { RESTART_BACKOFF = time.Second }()
+	opts := JobOpts{CPUWeight: 100, IOWeight: 100, RestartPolicy: RestartAlways}
+
+	// Run the job
+	con := &relaunchController{}
+	job, err := StartWithController(con, opts, cmd, "-c", "exit 0")
+	if err != nil {
+		t.Fatal("failed to start job: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status, err := job.Wait(ctx)
+	if err != nil {
+		t.Fatal("expected job to complete once it couldn't be relaunched: ", err)
+	}
+	// Assert the job gave up after the maximum number of attempts and failed
+	if status.State != JobFailed || status.ExitReason != StartFailed {
+		t.Errorf("expected job to fail with %s, actual %s with %s", StartFailed, status.State, status.ExitReason)
+	}
+	con.Lock()
+	defer con.Unlock()
+	if con.launches != 1+RESTART_MAX_LAUNCH_FAILURES {
+		t.Errorf("expected %d launches, actual %d", 1+RESTART_MAX_LAUNCH_FAILURES, con.launches)
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	expected := map[int32]time.Duration{0: time.Second, 1: 2 * time.Second, 3: 8 * time.Second, 20: 5 * time.Minute}
+	for restarts, backoff := range expected {
+		if actual := restartBackoff(restarts); actual != backoff {
+			t.Errorf("expected backoff after %d restarts to be %s, actual %s", restarts, backoff, actual)
+		}
+	}
+}
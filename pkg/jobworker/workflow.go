@@ -0,0 +1,253 @@
+package jobworker
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// DependencyCondition determines if a dependent job is started once an upstream job completes
+type DependencyCondition string
+
+const (
+	AfterSuccess    DependencyCondition = "success"    // the upstream job exited with code 0, the default
+	AfterCompletion DependencyCondition = "completion" // the upstream job completed, regardless of how
+)
+
+// Dependency is an upstream job that must complete before a dependent job is started
+type Dependency struct {
+	Job       *Job
+	Condition DependencyCondition
+}
+
+// parseCondition validates a DependencyCondition, defaulting to AfterSuccess
+func parseCondition(condition DependencyCondition) (DependencyCondition, error) {
+	switch condition {
+	case "":
+		return AfterSuccess, nil
+	case AfterSuccess, AfterCompletion:
+		return condition, nil
+	default:
+		return "", fmt.Errorf("dependency condition %q not valid", condition)
+	}
+}
+
+// StartAfter waits in a go routine for the dependencies of a pending job to complete, then submits the job to be
+// started, i.e. with Queue.Submit. If a dependency's condition isn't met the job completes without starting with the
+// exit reason Skipped, so failures propagate to it's own dependents. Stopping the job while it waits cancels it.
+func StartAfter(job *Job, deps []Dependency, submit func(*Job) error) error {
+	for i := range deps {
+		condition, err := parseCondition(deps[i].Condition)
+		if err != nil {
+			return err
+		}
+		deps[i].Condition = condition
+	}
+	job.Lock()
+	if !job.pending || job.waiting {
+		job.Unlock()
+		return fmt.Errorf("job %s has already been started", job.ID)
+	}
+	job.waiting = true
+	job.Unlock()
+	job.publishCreated()
+	go job.startAfter(deps, submit)
+	return nil
+}
+
+// startAfter blocks until every dependency has completed, or the job is cancelled, before submitting the job
+func (job *Job) startAfter(deps []Dependency, submit func(*Job) error) {
+	met := true
+	for _, dep := range deps {
+		select {
+		case <-dep.Job.done:
+		case <-job.done:
+			return
+		}
+		if dep.Condition == AfterSuccess && !dep.Job.succeeded() {
+			met = false
+		}
+	}
+	// The job was cancelled after it's dependencies completed
+	if !job.stopWaiting() {
+		return
+	}
+	if !met {
+		job.completePending(Skipped)
+		return
+	}
+	if err := submit(job); err != nil {
+		fmt.Printf("failed to start job %s after it's dependencies: %v\n", job.ID, err)
+		job.completePending(StartFailed)
+	}
+}
+
+// stopWaiting marks a job that's about to be submitted as no longer waiting, returns false if it was cancelled while
+// waiting
+func (job *Job) stopWaiting() bool {
+	job.Lock()
+	defer job.Unlock()
+	waiting := job.waiting
+	job.waiting = false
+	return waiting
+}
+
+// succeeded returns true if the job's command exited by itself with code 0
+func (job *Job) succeeded() bool {
+	status := job.Status()
+	return status.ExitReason == Exited && status.ExitCode == 0
+}
+
+// WorkflowNode is a job in a Workflow, named so other nodes can depend on it
+type WorkflowNode struct {
+	Name    string
+	Command string
+	Args    []string
+	Opts    JobOpts
+	After   []WorkflowDependency
+}
+
+// WorkflowDependency is an upstream node in a Workflow that must complete before a node is started
+type WorkflowDependency struct {
+	Node      string
+	Condition DependencyCondition
+}
+
+// WorkflowState summarises the state of all of a Workflow's nodes
+type WorkflowState string
+
+const (
+	WorkflowRunning   WorkflowState = "running"   // at least one node has not completed
+	WorkflowSucceeded WorkflowState = "succeeded" // every node exited with code 0
+	WorkflowFailed    WorkflowState = "failed"    // every node completed but at least one did not succeed
+)
+
+// Workflow is a DAG of jobs, where each job is started once the jobs it depends on have completed
+type Workflow struct {
+	ID    string
+	names []string
+	jobs  map[string]*Job
+}
+
+// WorkflowNodeStatus is the status of a Workflow node's job
+type WorkflowNodeStatus struct {
+	Name string
+	JobStatus
+}
+
+// WorkflowStatus is a snapshot of a Workflow and all of it's nodes, in the order they were submitted
+type WorkflowStatus struct {
+	ID    string
+	State WorkflowState
+	Nodes []WorkflowNodeStatus
+}
+
+// NewWorkflow validates the DAG of nodes, rejecting unknown dependencies and cycles, and creates a pending job for
+// each node owned by owner using the ResourceController. Each job is submitted to be started with submit once it's dependencies
+// complete, nodes without dependencies are submitted straight away.
+func NewWorkflow(con ResourceController, owner string, nodes []WorkflowNode, submit func(*Job) error) (*Workflow, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("workflow has no nodes")
+	}
+	byName := map[string]WorkflowNode{}
+	for _, node := range nodes {
+		if node.Name == "" {
+			return nil, fmt.Errorf("workflow node has no name")
+		}
+		if _, ok := byName[node.Name]; ok {
+			return nil, fmt.Errorf("workflow node %q is not unique", node.Name)
+		}
+		byName[node.Name] = node
+	}
+	for _, node := range nodes {
+		for _, dep := range node.After {
+			if _, ok := byName[dep.Node]; !ok {
+				return nil, fmt.Errorf("workflow node %q depends on unknown node %q", node.Name, dep.Node)
+			}
+			if _, err := parseCondition(dep.Condition); err != nil {
+				return nil, fmt.Errorf("workflow node %q: %w", node.Name, err)
+			}
+		}
+	}
+	if err := checkCycles(byName); err != nil {
+		return nil, err
+	}
+	// Create every job before any are submitted so dependencies can reference them
+	w := &Workflow{
+		ID:    uuid.New().String(),
+		names: []string{},
+		jobs:  map[string]*Job{},
+	}
+	for _, node := range nodes {
+		w.names = append(w.names, node.Name)
+		w.jobs[node.Name] = NewJobWithController(con, node.Opts, node.Command, node.Args...)
+		w.jobs[node.Name].SetOwner(owner)
+	}
+	for _, node := range nodes {
+		deps := []Dependency{}
+		for _, dep := range node.After {
+			deps = append(deps, Dependency{Job: w.jobs[dep.Node], Condition: dep.Condition})
+		}
+		if err := StartAfter(w.jobs[node.Name], deps, submit); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// checkCycles returns an error if a node depends on itself, directly or through other nodes
+func checkCycles(nodes map[string]WorkflowNode) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("workflow node %q depends on itself", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range nodes[name].After {
+			if err := visit(dep.Node); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for name := range nodes {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Jobs returns the workflow's jobs key'd by node name
+func (w *Workflow) Jobs() map[string]*Job {
+	jobs := map[string]*Job{}
+	for name, job := range w.jobs {
+		jobs[name] = job
+	}
+	return jobs
+}
+
+// Status returns the status of every node and summarises them as the workflow's state
+func (w *Workflow) Status() WorkflowStatus {
+	status := WorkflowStatus{ID: w.ID, State: WorkflowSucceeded, Nodes: []WorkflowNodeStatus{}}
+	for _, name := range w.names {
+		job := w.jobs[name]
+		if !job.isDone() {
+			status.State = WorkflowRunning
+		} else if status.State == WorkflowSucceeded && !job.succeeded() {
+			status.State = WorkflowFailed
+		}
+		status.Nodes = append(status.Nodes, WorkflowNodeStatus{Name: name, JobStatus: job.Status()})
+	}
+	return status
+}
@@ -0,0 +1,115 @@
+package jobworker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitForWorkflow polls the workflow's status until every node has completed
+func waitForWorkflow(t *testing.T, w *Workflow) WorkflowStatus {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if status := w.Status(); status.State != WorkflowRunning {
+			return status
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected workflow to complete, actual %+v", w.Status())
+	return WorkflowStatus{}
+}
+
+func TestWorkflow_Propagates_Failures_As_Skipped(t *testing.T) {
+	mockUserId()
+	con := &groupsController{}
+	w, err := NewWorkflow(con, "alice", []WorkflowNode{
+		{Name: "extract", Command: cmd, Args: []string{"-c", "exit 1"}},
+		{Name: "transform", Command: cmd, Args: []string{"-c", "exit 0"}, After: []WorkflowDependency{{Node: "extract"}}},
+		{Name: "load", Command: cmd, Args: []string{"-c", "exit 0"}, After: []WorkflowDependency{{Node: "transform", Condition: AfterSuccess}}},
+		{Name: "cleanup", Command: cmd, Args: []string{"-c", "exit 0"}, After: []WorkflowDependency{{Node: "load", Condition: AfterCompletion}}},
+	}, startJob)
+	if err != nil {
+		t.Fatal("failed to submit workflow: ", err)
+	}
+	status := waitForWorkflow(t, w)
+	if status.State != WorkflowFailed {
+		t.Errorf("expected workflow to fail, actual %s", status.State)
+	}
+	expected := map[string]ExitReason{"extract": Exited, "transform": Skipped, "load": Skipped, "cleanup": Exited}
+	for _, node := range status.Nodes {
+		if node.ExitReason != expected[node.Name] {
+			t.Errorf("expected node %s to have exit reason %s, actual %+v", node.Name, expected[node.Name], node.JobStatus)
+		}
+	}
+	// Assert skipped jobs never created a cgroup
+	if jobs := w.Jobs(); con.created(jobs["transform"].ID) || con.created(jobs["load"].ID) {
+		t.Error("expected skipped jobs to not create a cgroup")
+	}
+}
+
+func TestWorkflow_Succeeds(t *testing.T) {
+	mockUserId()
+	w, err := NewWorkflow(&groupsController{}, "alice", []WorkflowNode{
+		{Name: "a", Command: cmd, Args: []string{"-c", "exit 0"}},
+		{Name: "b", Command: cmd, Args: []string{"-c", "exit 0"}, After: []WorkflowDependency{{Node: "a"}}},
+		{Name: "c", Command: cmd, Args: []string{"-c", "exit 0"}, After: []WorkflowDependency{{Node: "a"}, {Node: "b"}}},
+	}, startJob)
+	if err != nil {
+		t.Fatal("failed to submit workflow: ", err)
+	}
+	if status := waitForWorkflow(t, w); status.State != WorkflowSucceeded {
+		t.Errorf("expected workflow to succeed, actual %+v", status)
+	}
+}
+
+func TestWorkflow_Rejects_Invalid_DAGs(t *testing.T) {
+	tests := map[string][]WorkflowNode{
+		"empty":     {},
+		"no name":   {{Command: cmd}},
+		"duplicate": {{Name: "a", Command: cmd}, {Name: "a", Command: cmd}},
+		"unknown":   {{Name: "a", Command: cmd, After: []WorkflowDependency{{Node: "b"}}}},
+		"condition": {{Name: "a", Command: cmd}, {Name: "b", Command: cmd, After: []WorkflowDependency{{Node: "a", Condition: "failure"}}}},
+		"self":      {{Name: "a", Command: cmd, After: []WorkflowDependency{{Node: "a"}}}},
+		"cycle": {
+			{Name: "a", Command: cmd, After: []WorkflowDependency{{Node: "c"}}},
+			{Name: "b", Command: cmd, After: []WorkflowDependency{{Node: "a"}}},
+			{Name: "c", Command: cmd, After: []WorkflowDependency{{Node: "b"}}},
+		},
+	}
+	for name, nodes := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewWorkflow(&groupsController{}, "alice", nodes, startJob); err == nil {
+				t.Error("expected workflow to be rejected")
+			}
+		})
+	}
+}
+
+func TestStartAfter_Stop_Cancels_Pending_Job(t *testing.T) {
+	mockUserId()
+	con := &groupsController{}
+	upstream, err := StartWithController(con, JobOpts{}, cmd, "-c", "sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := NewJobWithController(con, JobOpts{}, cmd, "-c", "exit 0")
+	if err = StartAfter(job, []Dependency{{Job: upstream}}, startJob); err != nil {
+		t.Fatal(err)
+	}
+	if status := job.Status(); !status.Pending {
+		t.Errorf("expected job to be pending, actual %+v", status)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = job.Stop(ctx); err != nil {
+		t.Errorf("expected to be able to stop pending job, error: %v", err)
+	}
+	if status := job.Status(); status.Pending || status.ExitReason != Stopped {
+		t.Errorf("expected pending job to be cancelled, actual %+v", status)
+	}
+	upstream.Stop(ctx)
+	time.Sleep(50 * time.Millisecond)
+	if con.created(job.ID) {
+		t.Error("expected cancelled job to not be started after it's dependency completed")
+	}
+}
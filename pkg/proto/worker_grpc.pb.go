@@ -24,8 +24,25 @@ const _ = grpc.SupportPackageIsVersion7
 type WorkerClient interface {
 	Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (*StartResponse, error)
 	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*StopResponse, error)
+	BulkStop(ctx context.Context, in *BulkStopRequest, opts ...grpc.CallOption) (*BulkStopResponse, error)
 	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	Wait(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*WaitResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Signal(ctx context.Context, in *SignalRequest, opts ...grpc.CallOption) (*SignalResponse, error)
 	Output(ctx context.Context, in *OutputRequest, opts ...grpc.CallOption) (Worker_OutputClient, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Worker_WatchClient, error)
+	Leaks(ctx context.Context, in *LeaksRequest, opts ...grpc.CallOption) (*LeaksResponse, error)
+	Schedule(ctx context.Context, in *ScheduleRequest, opts ...grpc.CallOption) (*ScheduleResponse, error)
+	ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ListSchedulesResponse, error)
+	PauseSchedule(ctx context.Context, in *PauseScheduleRequest, opts ...grpc.CallOption) (*PauseScheduleResponse, error)
+	ResumeSchedule(ctx context.Context, in *ResumeScheduleRequest, opts ...grpc.CallOption) (*ResumeScheduleResponse, error)
+	DeleteSchedule(ctx context.Context, in *DeleteScheduleRequest, opts ...grpc.CallOption) (*DeleteScheduleResponse, error)
+	SubmitWorkflow(ctx context.Context, in *SubmitWorkflowRequest, opts ...grpc.CallOption) (*SubmitWorkflowResponse, error)
+	WorkflowStatus(ctx context.Context, in *WorkflowStatusRequest, opts ...grpc.CallOption) (*WorkflowStatusResponse, error)
+	ArrayStatus(ctx context.Context, in *ArrayStatusRequest, opts ...grpc.CallOption) (*ArrayStatusResponse, error)
+	ArrayOutput(ctx context.Context, in *ArrayOutputRequest, opts ...grpc.CallOption) (Worker_ArrayOutputClient, error)
+	StopArray(ctx context.Context, in *StopArrayRequest, opts ...grpc.CallOption) (*StopArrayResponse, error)
 }
 
 type workerClient struct {
@@ -54,6 +71,15 @@ func (c *workerClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.C
 	return out, nil
 }
 
+func (c *workerClient) BulkStop(ctx context.Context, in *BulkStopRequest, opts ...grpc.CallOption) (*BulkStopResponse, error) {
+	out := new(BulkStopResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/BulkStop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *workerClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
 	out := new(StatusResponse)
 	err := c.cc.Invoke(ctx, "/JobWorker.Worker/Status", in, out, opts...)
@@ -63,6 +89,42 @@ func (c *workerClient) Status(ctx context.Context, in *StatusRequest, opts ...gr
 	return out, nil
 }
 
+func (c *workerClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) Wait(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*WaitResponse, error) {
+	out := new(WaitResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/Wait", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) Signal(ctx context.Context, in *SignalRequest, opts ...grpc.CallOption) (*SignalResponse, error) {
+	out := new(SignalResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/Signal", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *workerClient) Output(ctx context.Context, in *OutputRequest, opts ...grpc.CallOption) (Worker_OutputClient, error) {
 	stream, err := c.cc.NewStream(ctx, &Worker_ServiceDesc.Streams[0], "/JobWorker.Worker/Output", opts...)
 	if err != nil {
@@ -95,14 +157,185 @@ func (x *workerOutputClient) Recv() (*Data, error) {
 	return m, nil
 }
 
+func (c *workerClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Worker_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Worker_ServiceDesc.Streams[1], "/JobWorker.Worker/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &workerWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Worker_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type workerWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *workerWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *workerClient) Leaks(ctx context.Context, in *LeaksRequest, opts ...grpc.CallOption) (*LeaksResponse, error) {
+	out := new(LeaksResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/Leaks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) Schedule(ctx context.Context, in *ScheduleRequest, opts ...grpc.CallOption) (*ScheduleResponse, error) {
+	out := new(ScheduleResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/Schedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ListSchedulesResponse, error) {
+	out := new(ListSchedulesResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/ListSchedules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) PauseSchedule(ctx context.Context, in *PauseScheduleRequest, opts ...grpc.CallOption) (*PauseScheduleResponse, error) {
+	out := new(PauseScheduleResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/PauseSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) ResumeSchedule(ctx context.Context, in *ResumeScheduleRequest, opts ...grpc.CallOption) (*ResumeScheduleResponse, error) {
+	out := new(ResumeScheduleResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/ResumeSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) DeleteSchedule(ctx context.Context, in *DeleteScheduleRequest, opts ...grpc.CallOption) (*DeleteScheduleResponse, error) {
+	out := new(DeleteScheduleResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/DeleteSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) SubmitWorkflow(ctx context.Context, in *SubmitWorkflowRequest, opts ...grpc.CallOption) (*SubmitWorkflowResponse, error) {
+	out := new(SubmitWorkflowResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/SubmitWorkflow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) WorkflowStatus(ctx context.Context, in *WorkflowStatusRequest, opts ...grpc.CallOption) (*WorkflowStatusResponse, error) {
+	out := new(WorkflowStatusResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/WorkflowStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) ArrayStatus(ctx context.Context, in *ArrayStatusRequest, opts ...grpc.CallOption) (*ArrayStatusResponse, error) {
+	out := new(ArrayStatusResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/ArrayStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerClient) ArrayOutput(ctx context.Context, in *ArrayOutputRequest, opts ...grpc.CallOption) (Worker_ArrayOutputClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Worker_ServiceDesc.Streams[2], "/JobWorker.Worker/ArrayOutput", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &workerArrayOutputClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Worker_ArrayOutputClient interface {
+	Recv() (*Data, error)
+	grpc.ClientStream
+}
+
+type workerArrayOutputClient struct {
+	grpc.ClientStream
+}
+
+func (x *workerArrayOutputClient) Recv() (*Data, error) {
+	m := new(Data)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *workerClient) StopArray(ctx context.Context, in *StopArrayRequest, opts ...grpc.CallOption) (*StopArrayResponse, error) {
+	out := new(StopArrayResponse)
+	err := c.cc.Invoke(ctx, "/JobWorker.Worker/StopArray", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WorkerServer is the server API for Worker service.
 // All implementations must embed UnimplementedWorkerServer
 // for forward compatibility
 type WorkerServer interface {
 	Start(context.Context, *StartRequest) (*StartResponse, error)
 	Stop(context.Context, *StopRequest) (*StopResponse, error)
+	BulkStop(context.Context, *BulkStopRequest) (*BulkStopResponse, error)
 	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Wait(context.Context, *WaitRequest) (*WaitResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Signal(context.Context, *SignalRequest) (*SignalResponse, error)
 	Output(*OutputRequest, Worker_OutputServer) error
+	Watch(*WatchRequest, Worker_WatchServer) error
+	Leaks(context.Context, *LeaksRequest) (*LeaksResponse, error)
+	Schedule(context.Context, *ScheduleRequest) (*ScheduleResponse, error)
+	ListSchedules(context.Context, *ListSchedulesRequest) (*ListSchedulesResponse, error)
+	PauseSchedule(context.Context, *PauseScheduleRequest) (*PauseScheduleResponse, error)
+	ResumeSchedule(context.Context, *ResumeScheduleRequest) (*ResumeScheduleResponse, error)
+	DeleteSchedule(context.Context, *DeleteScheduleRequest) (*DeleteScheduleResponse, error)
+	SubmitWorkflow(context.Context, *SubmitWorkflowRequest) (*SubmitWorkflowResponse, error)
+	WorkflowStatus(context.Context, *WorkflowStatusRequest) (*WorkflowStatusResponse, error)
+	ArrayStatus(context.Context, *ArrayStatusRequest) (*ArrayStatusResponse, error)
+	ArrayOutput(*ArrayOutputRequest, Worker_ArrayOutputServer) error
+	StopArray(context.Context, *StopArrayRequest) (*StopArrayResponse, error)
 	mustEmbedUnimplementedWorkerServer()
 }
 
@@ -116,12 +349,63 @@ func (UnimplementedWorkerServer) Start(context.Context, *StartRequest) (*StartRe
 func (UnimplementedWorkerServer) Stop(context.Context, *StopRequest) (*StopResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
 }
+func (UnimplementedWorkerServer) BulkStop(context.Context, *BulkStopRequest) (*BulkStopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BulkStop not implemented")
+}
 func (UnimplementedWorkerServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
 }
+func (UnimplementedWorkerServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedWorkerServer) Wait(context.Context, *WaitRequest) (*WaitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Wait not implemented")
+}
+func (UnimplementedWorkerServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedWorkerServer) Signal(context.Context, *SignalRequest) (*SignalResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Signal not implemented")
+}
 func (UnimplementedWorkerServer) Output(*OutputRequest, Worker_OutputServer) error {
 	return status.Errorf(codes.Unimplemented, "method Output not implemented")
 }
+func (UnimplementedWorkerServer) Watch(*WatchRequest, Worker_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedWorkerServer) Leaks(context.Context, *LeaksRequest) (*LeaksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Leaks not implemented")
+}
+func (UnimplementedWorkerServer) Schedule(context.Context, *ScheduleRequest) (*ScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Schedule not implemented")
+}
+func (UnimplementedWorkerServer) ListSchedules(context.Context, *ListSchedulesRequest) (*ListSchedulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSchedules not implemented")
+}
+func (UnimplementedWorkerServer) PauseSchedule(context.Context, *PauseScheduleRequest) (*PauseScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseSchedule not implemented")
+}
+func (UnimplementedWorkerServer) ResumeSchedule(context.Context, *ResumeScheduleRequest) (*ResumeScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeSchedule not implemented")
+}
+func (UnimplementedWorkerServer) DeleteSchedule(context.Context, *DeleteScheduleRequest) (*DeleteScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSchedule not implemented")
+}
+func (UnimplementedWorkerServer) SubmitWorkflow(context.Context, *SubmitWorkflowRequest) (*SubmitWorkflowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitWorkflow not implemented")
+}
+func (UnimplementedWorkerServer) WorkflowStatus(context.Context, *WorkflowStatusRequest) (*WorkflowStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WorkflowStatus not implemented")
+}
+func (UnimplementedWorkerServer) ArrayStatus(context.Context, *ArrayStatusRequest) (*ArrayStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ArrayStatus not implemented")
+}
+func (UnimplementedWorkerServer) ArrayOutput(*ArrayOutputRequest, Worker_ArrayOutputServer) error {
+	return status.Errorf(codes.Unimplemented, "method ArrayOutput not implemented")
+}
+func (UnimplementedWorkerServer) StopArray(context.Context, *StopArrayRequest) (*StopArrayResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopArray not implemented")
+}
 func (UnimplementedWorkerServer) mustEmbedUnimplementedWorkerServer() {}
 
 // UnsafeWorkerServer may be embedded to opt out of forward compatibility for this service.
@@ -171,6 +455,24 @@ func _Worker_Stop_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Worker_BulkStop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BulkStopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).BulkStop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/BulkStop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).BulkStop(ctx, req.(*BulkStopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Worker_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(StatusRequest)
 	if err := dec(in); err != nil {
@@ -189,6 +491,78 @@ func _Worker_Status_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Worker_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_Wait_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WaitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).Wait(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/Wait",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).Wait(ctx, req.(*WaitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_Signal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).Signal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/Signal",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).Signal(ctx, req.(*SignalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Worker_Output_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(OutputRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -210,6 +584,228 @@ func (x *workerOutputServer) Send(m *Data) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Worker_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WorkerServer).Watch(m, &workerWatchServer{stream})
+}
+
+type Worker_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type workerWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *workerWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Worker_Leaks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).Leaks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/Leaks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).Leaks(ctx, req.(*LeaksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_Schedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).Schedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/Schedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).Schedule(ctx, req.(*ScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_ListSchedules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSchedulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).ListSchedules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/ListSchedules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).ListSchedules(ctx, req.(*ListSchedulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_PauseSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).PauseSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/PauseSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).PauseSchedule(ctx, req.(*PauseScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_ResumeSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).ResumeSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/ResumeSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).ResumeSchedule(ctx, req.(*ResumeScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_DeleteSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).DeleteSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/DeleteSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).DeleteSchedule(ctx, req.(*DeleteScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_SubmitWorkflow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitWorkflowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).SubmitWorkflow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/SubmitWorkflow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).SubmitWorkflow(ctx, req.(*SubmitWorkflowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_WorkflowStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WorkflowStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).WorkflowStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/WorkflowStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).WorkflowStatus(ctx, req.(*WorkflowStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_ArrayStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArrayStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).ArrayStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/ArrayStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).ArrayStatus(ctx, req.(*ArrayStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Worker_ArrayOutput_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ArrayOutputRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WorkerServer).ArrayOutput(m, &workerArrayOutputServer{stream})
+}
+
+type Worker_ArrayOutputServer interface {
+	Send(*Data) error
+	grpc.ServerStream
+}
+
+type workerArrayOutputServer struct {
+	grpc.ServerStream
+}
+
+func (x *workerArrayOutputServer) Send(m *Data) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Worker_StopArray_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopArrayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).StopArray(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/JobWorker.Worker/StopArray",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).StopArray(ctx, req.(*StopArrayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Worker_ServiceDesc is the grpc.ServiceDesc for Worker service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -225,10 +821,70 @@ var Worker_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Stop",
 			Handler:    _Worker_Stop_Handler,
 		},
+		{
+			MethodName: "BulkStop",
+			Handler:    _Worker_BulkStop_Handler,
+		},
 		{
 			MethodName: "Status",
 			Handler:    _Worker_Status_Handler,
 		},
+		{
+			MethodName: "List",
+			Handler:    _Worker_List_Handler,
+		},
+		{
+			MethodName: "Wait",
+			Handler:    _Worker_Wait_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Worker_Delete_Handler,
+		},
+		{
+			MethodName: "Signal",
+			Handler:    _Worker_Signal_Handler,
+		},
+		{
+			MethodName: "Leaks",
+			Handler:    _Worker_Leaks_Handler,
+		},
+		{
+			MethodName: "Schedule",
+			Handler:    _Worker_Schedule_Handler,
+		},
+		{
+			MethodName: "ListSchedules",
+			Handler:    _Worker_ListSchedules_Handler,
+		},
+		{
+			MethodName: "PauseSchedule",
+			Handler:    _Worker_PauseSchedule_Handler,
+		},
+		{
+			MethodName: "ResumeSchedule",
+			Handler:    _Worker_ResumeSchedule_Handler,
+		},
+		{
+			MethodName: "DeleteSchedule",
+			Handler:    _Worker_DeleteSchedule_Handler,
+		},
+		{
+			MethodName: "SubmitWorkflow",
+			Handler:    _Worker_SubmitWorkflow_Handler,
+		},
+		{
+			MethodName: "WorkflowStatus",
+			Handler:    _Worker_WorkflowStatus_Handler,
+		},
+		{
+			MethodName: "ArrayStatus",
+			Handler:    _Worker_ArrayStatus_Handler,
+		},
+		{
+			MethodName: "StopArray",
+			Handler:    _Worker_StopArray_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -236,6 +892,16 @@ var Worker_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Worker_Output_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Worker_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ArrayOutput",
+			Handler:       _Worker_ArrayOutput_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "pkg/proto/worker.proto",
 }
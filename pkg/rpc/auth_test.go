@@ -20,7 +20,7 @@ import (
 
 func TestMtlsRejectsLowTlsVersion(t *testing.T) {
 	s := NewServer()
-	go startServer(s)
+	startServer(s)
 	defer s.GracefulStop()
 
 	tlsConfig, err := loadTLSLowVersion(certs.Path("./client.pem"), certs.Path("./client-key.pem"), certs.Path("./root.pem"))
@@ -47,7 +47,7 @@ func TestMtlsRejectsLowTlsVersion(t *testing.T) {
 
 func TestMtlsChecksClientCert(t *testing.T) {
 	s := NewServer()
-	go startServer(s)
+	startServer(s)
 	defer s.GracefulStop()
 
 	tlsConfig, err := loadTLSWithoutClientCert(certs.Path("./root.pem"))
@@ -65,22 +65,25 @@ func TestMtlsChecksClientCert(t *testing.T) {
 	client := pb.NewWorkerClient(conn)
 
 	// Assert error is either tls cert required or the connection was already torn down
-	if _, err = Start(ctx, client, "bash", []string{"-c", "echo test"}, 100, 100, "100M"); err != nil {
+	if _, err = Start(ctx, client, "bash", []string{"-c", "echo test"}, &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}); err != nil {
 		if !strings.Contains(err.Error(), "tls: certificate required") && !strings.Contains(err.Error(), "write: broken pipe") {
 			t.Errorf("expected connection to be rejected for no client cert: actual error %v", err)
 		}
 	}
 }
 
-// startServer runs the gRPC JobWorker service blocking call Serve
-func startServer(s *grpc.Server) {
+// startServer listens before running the gRPC JobWorker service blocking call Serve in a go routine, so clients can
+// connect as soon as it returns
+func startServer(s *GrpcServer) {
 	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", 50051))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
-	}
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
 }
 
 // loadTLSLowVersion provides a client TLS config that sets the max tls version to 1.2
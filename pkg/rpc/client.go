@@ -7,19 +7,21 @@ import (
 	pb "github.com/teleport-jobworker/pkg/proto"
 )
 
-// Start sends a Start request to the gRPC server given a client and returns it's ID
-func Start(
-	ctx context.Context,
-	client pb.WorkerClient,
-	command string,
-	args []string,
-	cpuWeight, ioWeight int32,
-	memLimit string) (string, error) {
+// Start sends a Start request to the gRPC server given a client and returns it's ID. If after is given, the job is
+// pending until the jobs it depends on complete
+func Start(ctx context.Context, client pb.WorkerClient, command string, args []string, opts *pb.JobOpts, after ...*pb.Dependency) (string, error) {
 	req := &pb.StartRequest{
 		Command: command,
 		Args:    args,
-		Opts:    &pb.JobOpts{CpuWeight: cpuWeight, MemLimit: memLimit, IoWeight: ioWeight},
+		Opts:    opts,
+		After:   after,
 	}
+	return StartWith(ctx, client, req)
+}
+
+// StartWith sends a Start request to the gRPC server given a client and returns it's ID, allowing every field of the
+// request to be set, i.e. labels and annotations
+func StartWith(ctx context.Context, client pb.WorkerClient, req *pb.StartRequest) (string, error) {
 	resp, err := client.Start(ctx, req)
 	if err != nil {
 		return "", err
@@ -27,14 +29,52 @@ func Start(
 	return resp.GetId(), nil
 }
 
-// Stop sends a Stop request to the gRPC server given a client and checks for errors
+// StartArray sends a Start request with a count to the gRPC server given a client and returns the array's ID and the
+// IDs of it's jobs in index order
+func StartArray(ctx context.Context, client pb.WorkerClient, req *pb.StartRequest) (string, []string, error) {
+	resp, err := client.Start(ctx, req)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.GetArrayId(), resp.GetJobIds(), nil
+}
+
+// Stop sends a Stop request to the gRPC server given a client and checks for errors, the job is stopped in the
+// background using the server's default grace period
 func Stop(ctx context.Context, client pb.WorkerClient, id string) error {
-	req := &pb.StopRequest{Id: id}
-	_, err := client.Stop(ctx, req)
+	_, err := StopWith(ctx, client, &pb.StopRequest{Id: id})
+	return err
+}
+
+// StopWith sends a Stop request with a grace period or escalation to the gRPC server given a client and returns if the
+// job is still stopping
+func StopWith(ctx context.Context, client pb.WorkerClient, req *pb.StopRequest) (pb.JobState, error) {
+	resp, err := client.Stop(ctx, req)
 	if err != nil {
-		return err
+		return pb.JobState_JOB_STATE_UNSPECIFIED, err
+	}
+	return resp.GetState(), nil
+}
+
+// BulkStop sends a BulkStop request to the gRPC server given a client and returns the IDs of the jobs being stopped
+func BulkStop(ctx context.Context, client pb.WorkerClient, req *pb.BulkStopRequest) ([]string, error) {
+	resp, err := client.BulkStop(ctx, req)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return resp.Ids, nil
+}
+
+// Delete sends a Delete request to the gRPC server given a client and checks for errors
+func Delete(ctx context.Context, client pb.WorkerClient, id string) error {
+	_, err := client.Delete(ctx, &pb.DeleteRequest{Id: id})
+	return err
+}
+
+// Signal sends a Signal request to the gRPC server given a client and checks for errors
+func Signal(ctx context.Context, client pb.WorkerClient, id, signal, target string) error {
+	_, err := client.Signal(ctx, &pb.SignalRequest{Id: id, Signal: signal, Target: target})
+	return err
 }
 
 // Status sends a Status request to the gRPC server given a client and returns a JobStatus
@@ -47,6 +87,108 @@ func Status(ctx context.Context, client pb.WorkerClient, id string) (*pb.JobStat
 	return resp.JobStatus, nil
 }
 
+// List sends a List request to the gRPC server given a client and returns a page of jobs and the token for the next page
+func List(ctx context.Context, client pb.WorkerClient, req *pb.ListRequest) ([]*pb.JobStatus, string, error) {
+	resp, err := client.List(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Jobs, resp.NextPageToken, nil
+}
+
+// Wait sends a Wait request to the gRPC server given a client and returns the job's final JobStatus once it has completed
+func Wait(ctx context.Context, client pb.WorkerClient, id string) (*pb.JobStatus, error) {
+	resp, err := client.Wait(ctx, &pb.WaitRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	return resp.JobStatus, nil
+}
+
+// Watch sends a Watch request to the gRPC server given a client and calls handle with each job event received, until
+// the stream ends
+func Watch(ctx context.Context, client pb.WorkerClient, req *pb.WatchRequest, handle func(*pb.Event)) error {
+	stream, err := client.Watch(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		handle(event)
+	}
+}
+
+// Leaks sends a Leaks request to the gRPC server and returns the resources that failed to be cleaned up
+func Leaks(ctx context.Context, client pb.WorkerClient) ([]*pb.Leak, error) {
+	resp, err := client.Leaks(ctx, &pb.LeaksRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetLeaks(), nil
+}
+
+// Schedule sends a Schedule request to the gRPC server to launch a job each time the cron spec is due and returns the
+// schedule's ID
+func Schedule(ctx context.Context, client pb.WorkerClient, spec, policy, command string, args []string, opts *pb.JobOpts) (string, error) {
+	req := &pb.ScheduleRequest{
+		Spec:              spec,
+		Command:           command,
+		Args:              args,
+		Opts:              opts,
+		ConcurrencyPolicy: policy,
+	}
+	resp, err := client.Schedule(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return resp.GetId(), nil
+}
+
+// ListSchedules sends a ListSchedules request to the gRPC server and returns the status of the client's schedules
+func ListSchedules(ctx context.Context, client pb.WorkerClient) ([]*pb.ScheduleStatus, error) {
+	resp, err := client.ListSchedules(ctx, &pb.ListSchedulesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetSchedules(), nil
+}
+
+// PauseSchedule sends a PauseSchedule request to the gRPC server given a client and checks for errors
+func PauseSchedule(ctx context.Context, client pb.WorkerClient, id string) error {
+	_, err := client.PauseSchedule(ctx, &pb.PauseScheduleRequest{Id: id})
+	return err
+}
+
+// ResumeSchedule sends a ResumeSchedule request to the gRPC server given a client and checks for errors
+func ResumeSchedule(ctx context.Context, client pb.WorkerClient, id string) error {
+	_, err := client.ResumeSchedule(ctx, &pb.ResumeScheduleRequest{Id: id})
+	return err
+}
+
+// DeleteSchedule sends a DeleteSchedule request to the gRPC server given a client and checks for errors
+func DeleteSchedule(ctx context.Context, client pb.WorkerClient, id string) error {
+	_, err := client.DeleteSchedule(ctx, &pb.DeleteScheduleRequest{Id: id})
+	return err
+}
+
+// SubmitWorkflow sends a SubmitWorkflow request to the gRPC server and returns the workflow's ID and the job ID of
+// each node key'd by node name
+func SubmitWorkflow(ctx context.Context, client pb.WorkerClient, nodes []*pb.WorkflowNode) (string, map[string]string, error) {
+	resp, err := client.SubmitWorkflow(ctx, &pb.SubmitWorkflowRequest{Nodes: nodes})
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.GetId(), resp.GetJobs(), nil
+}
+
+// WorkflowStatus sends a WorkflowStatus request to the gRPC server and returns the status of the workflow's nodes
+func WorkflowStatus(ctx context.Context, client pb.WorkerClient, id string) (*pb.WorkflowStatusResponse, error) {
+	return client.WorkflowStatus(ctx, &pb.WorkflowStatusRequest{Id: id})
+}
+
 // Logs sends a Output request to the gRPC server and logs the output stream
 func Logs(ctx context.Context, client pb.WorkerClient, id string, follow bool) error {
 	req := &pb.OutputRequest{Id: id, Follow: follow}
@@ -62,3 +204,33 @@ func Logs(ctx context.Context, client pb.WorkerClient, id string, follow bool) e
 		fmt.Println(string(data.GetBytes()))
 	}
 }
+
+// ArrayStatus sends an ArrayStatus request to the gRPC server and returns the status of the array's jobs
+func ArrayStatus(ctx context.Context, client pb.WorkerClient, id string) (*pb.ArrayStatusResponse, error) {
+	return client.ArrayStatus(ctx, &pb.ArrayStatusRequest{Id: id})
+}
+
+// StopArray sends a StopArray request to the gRPC server and returns the IDs of the array's jobs being stopped
+func StopArray(ctx context.Context, client pb.WorkerClient, req *pb.StopArrayRequest) ([]string, error) {
+	resp, err := client.StopArray(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetIds(), nil
+}
+
+// ArrayLogs sends an ArrayOutput request to the gRPC server and logs the output stream, each line prefixed with the
+// index of the job that wrote it
+func ArrayLogs(ctx context.Context, client pb.WorkerClient, id string, follow bool) error {
+	stream, err := client.ArrayOutput(ctx, &pb.ArrayOutputRequest{Id: id, Follow: follow})
+	if err != nil {
+		return err
+	}
+	for {
+		data, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data.GetBytes()))
+	}
+}
@@ -1,11 +1,24 @@
 package rpc
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/teleport-jobworker/pkg/jobworker"
+	pb "github.com/teleport-jobworker/pkg/proto"
+	"google.golang.org/protobuf/proto"
 )
 
+// ErrNameInUse is returned when starting a job with the same name as one of the owner's jobs that hasn't completed
+var ErrNameInUse = errors.New("job name is already in use")
+
+// ErrAmbiguousID is returned when a prefix of a job's ID matches more than one of the owner's jobs
+var ErrAmbiguousID = errors.New("job ID prefix is ambiguous")
+
 // jobList is a map of Jobs key'd by their ID
 type jobList map[string]*jobworker.Job
 
@@ -13,7 +26,8 @@ type jobList map[string]*jobworker.Job
 // TODO in production this would be persisted in an actual DB
 type JobsDB struct {
 	sync.RWMutex
-	jobs map[string]jobList // list of jobLists key'd by owner
+	jobs  map[string]jobList // list of jobLists key'd by owner
+	names map[string]jobList // the most recent job started with each name, key'd by owner then name
 }
 
 // Get returns a Job for an owner and job ID, returning nil if not found
@@ -31,6 +45,129 @@ func (db *JobsDB) Get(owner, id string) *jobworker.Job {
 	return job
 }
 
+// ClaimName reserves the job's name for it, unless another of the owner's jobs with the name hasn't completed. Jobs
+// without a name don't need to claim one
+func (db *JobsDB) ClaimName(owner string, job *jobworker.Job) error {
+	name := job.Status().Name
+	if name == "" {
+		return nil
+	}
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.names[owner]; !ok {
+		db.names[owner] = jobList{}
+	}
+	if claimed, ok := db.names[owner][name]; ok && claimed != job && claimed.Status().Finished.IsZero() {
+		return fmt.Errorf("%w: %q is used by job %s", ErrNameInUse, name, claimed.ID)
+	}
+	db.names[owner][name] = job
+	return nil
+}
+
+// ReleaseName releases the job's name if it failed to start, an earlier job with the name can't be resolved by it
+// again since it's completed
+func (db *JobsDB) ReleaseName(owner string, job *jobworker.Job) {
+	db.Lock()
+	defer db.Unlock()
+	name := job.Status().Name
+	if db.names[owner][name] == job {
+		delete(db.names[owner], name)
+	}
+}
+
+// Resolve returns an owner's job given it's ID, name or an unambiguous prefix of it's ID, in that order, returning
+// nil if not found
+func (db *JobsDB) Resolve(owner, ref string) (*jobworker.Job, error) {
+	db.RLock()
+	defer db.RUnlock()
+	if job, ok := db.jobs[owner][ref]; ok {
+		return job, nil
+	}
+	// Jobs that are still being started have claimed their name but aren't in the DB yet
+	if job, ok := db.names[owner][ref]; ok && db.jobs[owner][job.ID] != nil {
+		return job, nil
+	}
+	if ref == "" {
+		return nil, nil
+	}
+	matched := []string{}
+	for id := range db.jobs[owner] {
+		if strings.HasPrefix(id, ref) {
+			matched = append(matched, id)
+		}
+	}
+	if len(matched) > 1 {
+		slices.Sort(matched)
+		return nil, fmt.Errorf("%w: %q matches jobs %s", ErrAmbiguousID, ref, strings.Join(matched, ", "))
+	} else if len(matched) == 0 {
+		return nil, nil
+	}
+	return db.jobs[owner][matched[0]], nil
+}
+
+// Owners returns every owner with jobs
+func (db *JobsDB) Owners() []string {
+	db.RLock()
+	defer db.RUnlock()
+	owners := []string{}
+	for owner := range db.jobs {
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// JobFilter selects jobs by their status, the zero value matches every job
+type JobFilter struct {
+	States        []jobworker.JobState // any of the states
+	Command       string               // substring of the command and it's args
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Selector      jobworker.Selector // labels
+}
+
+// Matches returns true if a job's status satisfies every field of the filter
+func (f JobFilter) Matches(status jobworker.JobStatus) bool {
+	if len(f.States) > 0 && !slices.Contains(f.States, status.State) {
+		return false
+	}
+	if f.Command != "" && !strings.Contains(strings.Join(append([]string{status.Command}, status.Args...), " "), f.Command) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && !status.Created.After(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !status.Created.Before(f.CreatedBefore) {
+		return false
+	}
+	return f.Selector.Matches(status.Labels)
+}
+
+// List returns an owner's jobs matching the filter, oldest first
+func (db *JobsDB) List(owner string, filter JobFilter) []*jobworker.Job {
+	db.RLock()
+	jobs := []*jobworker.Job{}
+	for _, job := range db.jobs[owner] {
+		jobs = append(jobs, job)
+	}
+	db.RUnlock()
+	statuses := map[*jobworker.Job]jobworker.JobStatus{}
+	matched := []*jobworker.Job{}
+	for _, job := range jobs {
+		status := job.Status()
+		if filter.Matches(status) {
+			statuses[job] = status
+			matched = append(matched, job)
+		}
+	}
+	slices.SortFunc(matched, func(a, b *jobworker.Job) int {
+		if c := statuses[a].Created.Compare(statuses[b].Created); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return matched
+}
+
 // Update upserts a job into the owner's list of jobs, where any existing job would be updated
 func (db *JobsDB) Update(owner string, job *jobworker.Job) {
 	db.Lock()
@@ -50,5 +187,251 @@ func (db *JobsDB) Remove(owner string, id string) {
 	if !ok {
 		db.jobs[owner] = jobList{}
 	}
+	if job, ok := db.jobs[owner][id]; ok {
+		name := job.Status().Name
+		if db.names[owner][name] == job {
+			delete(db.names[owner], name)
+		}
+	}
 	delete(db.jobs[owner], id)
 }
+
+// scheduleList is a map of Schedules key'd by their ID
+type scheduleList map[string]*jobworker.Schedule
+
+// SchedulesDB is an in memory database of schedules firstly key'd by owner and then ID
+// TODO in production this would be persisted in an actual DB so schedules survive restarts
+type SchedulesDB struct {
+	sync.RWMutex
+	schedules map[string]scheduleList // list of scheduleLists key'd by owner
+}
+
+// Get returns a Schedule for an owner and schedule ID, returning nil if not found
+func (db *SchedulesDB) Get(owner, id string) *jobworker.Schedule {
+	db.RLock()
+	defer db.RUnlock()
+	return db.schedules[owner][id]
+}
+
+// List returns all of an owner's schedules
+func (db *SchedulesDB) List(owner string) []*jobworker.Schedule {
+	db.RLock()
+	defer db.RUnlock()
+	schedules := []*jobworker.Schedule{}
+	for _, s := range db.schedules[owner] {
+		schedules = append(schedules, s)
+	}
+	return schedules
+}
+
+// Update upserts a schedule into the owner's list of schedules
+func (db *SchedulesDB) Update(owner string, s *jobworker.Schedule) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.schedules[owner]; !ok {
+		db.schedules[owner] = scheduleList{}
+	}
+	db.schedules[owner][s.ID] = s
+}
+
+// Remove deletes a schedule from an owner's schedule list
+func (db *SchedulesDB) Remove(owner, id string) {
+	db.Lock()
+	defer db.Unlock()
+	delete(db.schedules[owner], id)
+}
+
+// Owners returns every owner with schedules
+func (db *SchedulesDB) Owners() []string {
+	db.RLock()
+	defer db.RUnlock()
+	owners := []string{}
+	for owner := range db.schedules {
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// workflowList is a map of Workflows key'd by their ID
+type workflowList map[string]*jobworker.Workflow
+
+// WorkflowsDB is an in memory database of workflows firstly key'd by owner and then ID
+// TODO in production this would be persisted in an actual DB
+type WorkflowsDB struct {
+	sync.RWMutex
+	workflows map[string]workflowList // list of workflowLists key'd by owner
+}
+
+// Get returns a Workflow for an owner and workflow ID, returning nil if not found
+func (db *WorkflowsDB) Get(owner, id string) *jobworker.Workflow {
+	db.RLock()
+	defer db.RUnlock()
+	return db.workflows[owner][id]
+}
+
+// Update upserts a workflow into the owner's workflows
+func (db *WorkflowsDB) Update(owner string, w *jobworker.Workflow) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.workflows[owner]; !ok {
+		db.workflows[owner] = workflowList{}
+	}
+	db.workflows[owner][w.ID] = w
+}
+
+// List returns all of an owner's workflows
+func (db *WorkflowsDB) List(owner string) []*jobworker.Workflow {
+	db.RLock()
+	defer db.RUnlock()
+	workflows := []*jobworker.Workflow{}
+	for _, w := range db.workflows[owner] {
+		workflows = append(workflows, w)
+	}
+	return workflows
+}
+
+// Remove deletes a workflow from an owner's workflows
+func (db *WorkflowsDB) Remove(owner, id string) {
+	db.Lock()
+	defer db.Unlock()
+	delete(db.workflows[owner], id)
+}
+
+// Owners returns every owner with workflows
+func (db *WorkflowsDB) Owners() []string {
+	db.RLock()
+	defer db.RUnlock()
+	owners := []string{}
+	for owner := range db.workflows {
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// arrayList is a map of Arrays key'd by their ID
+type arrayList map[string]*jobworker.Array
+
+// ArraysDB is an in memory database of job arrays firstly key'd by owner and then ID
+// TODO in production this would be persisted in an actual DB
+type ArraysDB struct {
+	sync.RWMutex
+	arrays map[string]arrayList // list of arrayLists key'd by owner
+}
+
+// Get returns an Array for an owner and array ID, returning nil if not found
+func (db *ArraysDB) Get(owner, id string) *jobworker.Array {
+	db.RLock()
+	defer db.RUnlock()
+	return db.arrays[owner][id]
+}
+
+// Update upserts an array into the owner's arrays
+func (db *ArraysDB) Update(owner string, a *jobworker.Array) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.arrays[owner]; !ok {
+		db.arrays[owner] = arrayList{}
+	}
+	db.arrays[owner][a.ID] = a
+}
+
+// List returns all of an owner's arrays
+func (db *ArraysDB) List(owner string) []*jobworker.Array {
+	db.RLock()
+	defer db.RUnlock()
+	arrays := []*jobworker.Array{}
+	for _, a := range db.arrays[owner] {
+		arrays = append(arrays, a)
+	}
+	return arrays
+}
+
+// Remove deletes an array from an owner's arrays
+func (db *ArraysDB) Remove(owner, id string) {
+	db.Lock()
+	defer db.Unlock()
+	delete(db.arrays[owner], id)
+}
+
+// Owners returns every owner with arrays
+func (db *ArraysDB) Owners() []string {
+	db.RLock()
+	defer db.RUnlock()
+	owners := []string{}
+	for owner := range db.arrays {
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// startRecord remembers the job or array started for a StartRequest's request ID, so a retried request returns the
+// same response
+type startRecord struct {
+	req     *pb.StartRequest  // the request without it's request ID
+	res     *pb.StartResponse // set once the job has started, nil if it failed to
+	done    chan struct{}     // closed once the job has started or failed to
+	expires time.Time         // zero until the job has started
+}
+
+// RequestsDB is an in memory database of the jobs started by StartRequests with a request ID, firstly key'd by owner
+// and then request ID, which are kept for REQUEST_ID_WINDOW after the job starts
+// TODO in production this would be persisted with the jobs so replays are detected across restarts
+type RequestsDB struct {
+	sync.Mutex
+	records map[string]map[string]*startRecord
+}
+
+// Reserve returns the record of an owner's request ID and true if this request reserved it, i.e. it should start the
+// job then Complete or Release the reservation. Otherwise the record belongs to an earlier request with the same ID
+// that may still be starting it's job, wait for done before reading the job ID
+func (db *RequestsDB) Reserve(owner string, req *pb.StartRequest, now time.Time) (*startRecord, bool) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.records[owner]; !ok {
+		db.records[owner] = map[string]*startRecord{}
+	}
+	record, ok := db.records[owner][req.RequestId]
+	if ok && (record.expires.IsZero() || now.Before(record.expires)) {
+		return record, false
+	}
+	spec := proto.Clone(req).(*pb.StartRequest)
+	spec.RequestId = ""
+	record = &startRecord{req: spec, done: make(chan struct{})}
+	db.records[owner][req.RequestId] = record
+	return record, true
+}
+
+// Complete records the response of a reserved request ID, replays return it until the window expires
+func (db *RequestsDB) Complete(owner, requestID string, res *pb.StartResponse, now time.Time) {
+	db.Lock()
+	defer db.Unlock()
+	record := db.records[owner][requestID]
+	record.res = res
+	record.expires = now.Add(jobworker.REQUEST_ID_WINDOW)
+	close(record.done)
+}
+
+// Release removes a reserved request ID whose job failed to start, so it can be retried
+func (db *RequestsDB) Release(owner, requestID string) {
+	db.Lock()
+	defer db.Unlock()
+	record := db.records[owner][requestID]
+	delete(db.records[owner], requestID)
+	close(record.done)
+}
+
+// Expire removes the request IDs whose window has expired
+func (db *RequestsDB) Expire(now time.Time) {
+	db.Lock()
+	defer db.Unlock()
+	for owner, records := range db.records {
+		for requestID, record := range records {
+			if !record.expires.IsZero() && !now.Before(record.expires) {
+				delete(records, requestID)
+			}
+		}
+		if len(records) == 0 {
+			delete(db.records, owner)
+		}
+	}
+}
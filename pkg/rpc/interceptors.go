@@ -2,24 +2,96 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 
+	"github.com/teleport-jobworker/pkg/jobworker"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
+// adminMethods are the gRPC methods only available to owners in jobworker.ADMIN_OWNERS
+var adminMethods = map[string]bool{
+	"/JobWorker.Worker/Leaks": true,
+}
+
+// openMethods are the gRPC methods any client can call since they don't reference an existing job or schedule, or only
+// return the client's own jobs, e.g. ArrayOutput looks up the array by owner
+var openMethods = map[string]bool{
+	"/JobWorker.Worker/Start":          true,
+	"/JobWorker.Worker/Schedule":       true,
+	"/JobWorker.Worker/ListSchedules":  true,
+	"/JobWorker.Worker/SubmitWorkflow": true,
+	"/JobWorker.Worker/Watch":          true,
+	"/JobWorker.Worker/List":           true,
+	"/JobWorker.Worker/BulkStop":       true,
+	"/JobWorker.Worker/ArrayOutput":    true,
+}
+
+// scheduleMethods are the gRPC methods where the request's ID references a schedule rather than a job
+var scheduleMethods = map[string]bool{
+	"/JobWorker.Worker/PauseSchedule":  true,
+	"/JobWorker.Worker/ResumeSchedule": true,
+	"/JobWorker.Worker/DeleteSchedule": true,
+}
+
+// workflowMethods are the gRPC methods where the request's ID references a workflow rather than a job
+var workflowMethods = map[string]bool{
+	"/JobWorker.Worker/WorkflowStatus": true,
+}
+
+// arrayMethods are the gRPC methods where the request's ID references a job array rather than a job
+var arrayMethods = map[string]bool{
+	"/JobWorker.Worker/ArrayStatus": true,
+	"/JobWorker.Worker/StopArray":   true,
+}
+
 // Middleware implements the unary and stream interceptors on the gRPC server for authorization
 type Middleware struct {
-	db DB
+	db        DB
+	schedules *SchedulesDB
+	workflows *WorkflowsDB
+	arrays    *ArraysDB
+}
+
+// authz resolves the job ID, name or ID prefix of a request to one of the owner's jobs and replaces the request's ID
+// with the job's ID, so the handler can get the job by it's ID
+func authz(db DB, owner string, req GenericRequest) error {
+	job, err := resolveJob(db, owner, req.GetId())
+	if err != nil {
+		return err
+	}
+	if msg, ok := req.(proto.Message); ok {
+		if field := msg.ProtoReflect().Descriptor().Fields().ByName("id"); field != nil {
+			msg.ProtoReflect().Set(field, protoreflect.ValueOfString(job.ID))
+		}
+	}
+	return nil
+}
+
+// resolveJob finds an owner's job by it's ID, name or a unique prefix of it's ID, returning ErrNotFound if the owner
+// has no such job and InvalidArgument if the prefix matches more than one job
+func resolveJob(db DB, owner, ref string) (*jobworker.Job, error) {
+	job, err := db.Resolve(owner, ref)
+	if errors.Is(err, ErrAmbiguousID) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if job == nil {
+		return nil, ErrNotFound
+	}
+	return job, nil
 }
 
-// authz returns true if the jobId exists under the given owner
-func authz(db DB, owner, jobId string) bool {
-	return db.Get(owner, jobId) == nil
+// isAdmin returns true if the owner is configured as an admin
+func isAdmin(owner string) bool {
+	return slices.Contains(jobworker.ADMIN_OWNERS, owner)
 }
 
 // addOwnerMetadata extracts the common name from the tls context and appends it to the grpc's context metadata
@@ -52,16 +124,46 @@ func (m *Middleware) Unary(ctx context.Context, req interface{}, info *grpc.Unar
 	if newCtx == nil {
 		return nil, status.Errorf(codes.Unauthenticated, "no common name available in client cert")
 	}
-	// Allow any client to start a job, check ownership for status, logs and stop
-	if info.FullMethod != "/JobWorker.Worker/Start" {
-		r, ok := req.(GenericRequest)
-		if !ok {
-			return nil, status.Errorf(codes.InvalidArgument, "could not parse request")
+	// Admin methods don't reference a job, instead check the owner is an admin
+	if adminMethods[info.FullMethod] {
+		fmt.Printf("%s request from owner: %s\n", info.FullMethod, owner)
+		if !isAdmin(owner) {
+			return nil, status.Errorf(codes.PermissionDenied, "admin access required")
+		}
+		return handler(newCtx, req)
+	}
+	// Allow any client to start a job or workflow or create and list their schedules, check ownership for status, logs and stop
+	if openMethods[info.FullMethod] {
+		return handler(newCtx, req)
+	}
+	r, ok := req.(GenericRequest)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "could not parse request")
+	}
+	if scheduleMethods[info.FullMethod] {
+		fmt.Printf("%s request from owner: %s, schedule UUID: %s\n", info.FullMethod, owner, r.GetId())
+		if m.schedules.Get(owner, r.GetId()) == nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid schedule UUID")
 		}
-		fmt.Printf("%s request from owner: %s, job UUID: %s\n", info.FullMethod, owner, r.GetId())
-		if authz(m.db, owner, r.GetId()) {
-			return nil, status.Errorf(codes.Unauthenticated, "invalid job UUID")
+		return handler(newCtx, req)
+	}
+	if workflowMethods[info.FullMethod] {
+		fmt.Printf("%s request from owner: %s, workflow UUID: %s\n", info.FullMethod, owner, r.GetId())
+		if m.workflows.Get(owner, r.GetId()) == nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid workflow UUID")
+		}
+		return handler(newCtx, req)
+	}
+	if arrayMethods[info.FullMethod] {
+		fmt.Printf("%s request from owner: %s, array UUID: %s\n", info.FullMethod, owner, r.GetId())
+		if m.arrays.Get(owner, r.GetId()) == nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid array UUID")
 		}
+		return handler(newCtx, req)
+	}
+	fmt.Printf("%s request from owner: %s, job UUID: %s\n", info.FullMethod, owner, r.GetId())
+	if err := authz(m.db, owner, r); err != nil {
+		return nil, err
 	}
 	return handler(newCtx, req)
 }
@@ -88,10 +190,18 @@ func (s *wrappedStream) RecvMsg(m interface{}) error {
 		return status.Errorf(codes.InvalidArgument, "could not parse logs request")
 	}
 	fmt.Printf("/JobWorker.Worker/Logs stream request from owner: %s, job UUID: %s\n", s.owner, req.GetId())
-	if authz(s.db, s.owner, req.GetId()) {
-		return status.Errorf(codes.Unauthenticated, "invalid job UUID")
-	}
-	return nil
+	return authz(s.db, s.owner, req)
+}
+
+// ownerStream wraps the ServerStream with a context to pass metadata to the handler of an open stream, which only
+// returns the owner's jobs
+type ownerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (o *ownerStream) Context() context.Context {
+	return o.ctx
 }
 
 // Stream implements the Logs authz scheme using a grpc interceptor
@@ -101,5 +211,8 @@ func (m *Middleware) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServ
 	if newCtx == nil {
 		return status.Errorf(codes.Unauthenticated, "no common name available in client cert")
 	}
+	if openMethods[info.FullMethod] {
+		return handler(srv, &ownerStream{ss, newCtx})
+	}
 	return handler(srv, &wrappedStream{ss, newCtx, m.db, owner})
 }
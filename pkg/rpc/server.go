@@ -5,45 +5,133 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/teleport-jobworker/certs"
 	"github.com/teleport-jobworker/pkg/jobworker"
 	pb "github.com/teleport-jobworker/pkg/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // ErrNotFound is returned when a job was not found using the UUID
 var ErrNotFound = status.Errorf(codes.Unauthenticated, "invalid job UUID")
 
+// ErrScheduleNotFound is returned when a schedule was not found using the UUID
+var ErrScheduleNotFound = status.Errorf(codes.Unauthenticated, "invalid schedule UUID")
+
+// ErrWorkflowNotFound is returned when a workflow was not found using the UUID
+var ErrWorkflowNotFound = status.Errorf(codes.Unauthenticated, "invalid workflow UUID")
+
+// ErrArrayNotFound is returned when a job array was not found using the UUID
+var ErrArrayNotFound = status.Errorf(codes.Unauthenticated, "invalid array UUID")
+
 // DB defines how to persist jobs across rpc requests, including ownership for authz
 type DB interface {
 	Get(string, string) *jobworker.Job
 	Update(string, *jobworker.Job)
 	Remove(string, string)
+	Owners() []string
+	List(string, JobFilter) []*jobworker.Job
+	Resolve(string, string) (*jobworker.Job, error)
+	ClaimName(string, *jobworker.Job) error
+	ReleaseName(string, *jobworker.Job)
 }
 
 // Server implements the grpc service Worker
 type Server struct {
 	pb.UnimplementedWorkerServer
-	db DB
+	db        DB
+	schedules *SchedulesDB
+	workflows *WorkflowsDB
+	arrays    *ArraysDB
+	requests  *RequestsDB
+	queue     *jobworker.Queue
+	admission *jobworker.Admission
+	con       jobworker.ResourceController
+	retention retention
+	done      chan struct{} // closed when the server stops, ending the reaper
+	closeOnce sync.Once
 }
 
-// newServer returns an initialized Server with in memory DB of jobs
-func newServer(db DB) *Server {
+// retention is the policy for reaping completed jobs, workflows and arrays, see jobworker.RETAIN_JOBS_PER_OWNER,
+// jobworker.RETAIN_JOBS_FOR and jobworker.REAP_INTERVAL
+type retention struct {
+	keep     int
+	ttl      time.Duration
+	interval time.Duration
+}
+
+// newServer returns an initialized Server with in memory DBs of jobs, schedules, workflows, arrays and start request
+// IDs, a queue that limits running jobs, the admission control the queue starts jobs with and the resource controller
+// used to start jobs. The retention policy is read from the jobworker config once, when the server is created
+func newServer(db DB, schedules *SchedulesDB, workflows *WorkflowsDB, arrays *ArraysDB, requests *RequestsDB, queue *jobworker.Queue, admission *jobworker.Admission, con jobworker.ResourceController) *Server {
 	return &Server{
-		db: db,
+		db:        db,
+		schedules: schedules,
+		workflows: workflows,
+		arrays:    arrays,
+		requests:  requests,
+		queue:     queue,
+		admission: admission,
+		con:       con,
+		retention: retention{
+			keep:     jobworker.RETAIN_JOBS_PER_OWNER,
+			ttl:      jobworker.RETAIN_JOBS_FOR,
+			interval: jobworker.REAP_INTERVAL,
+		},
+		done: make(chan struct{}),
 	}
 }
 
+// close stops the server's background work, the reaper and every schedule, it's safe to call more than once
+func (s *Server) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		for _, owner := range s.schedules.Owners() {
+			for _, schedule := range s.schedules.List(owner) {
+				schedule.Delete()
+			}
+		}
+	})
+}
+
+// GrpcServer is a grpc.Server serving a Server, stopping it also stops the Server's reaper and schedules
+type GrpcServer struct {
+	*grpc.Server
+	server *Server
+}
+
+// GracefulStop stops reaping jobs and launching scheduled jobs and gracefully stops the grpc.Server
+func (s *GrpcServer) GracefulStop() {
+	s.server.close()
+	s.Server.GracefulStop()
+}
+
+// Stop stops reaping jobs and launching scheduled jobs and stops the grpc.Server
+func (s *GrpcServer) Stop() {
+	s.server.close()
+	s.Server.Stop()
+}
+
 // NewServer returns a grpc.Server that implements WorkerServer set up with mtls and authz middleware
-func NewServer() *grpc.Server {
+func NewServer() *GrpcServer {
 	// Load TLS certs
 	cert, err := tls.LoadX509KeyPair(certs.Path("./server.pem"), certs.Path("./server-key.pem"))
 	if err != nil {
@@ -66,13 +154,38 @@ func NewServer() *grpc.Server {
 		MinVersion:         tls.VersionTLS13,
 		InsecureSkipVerify: false,
 	}
-	// Initialise jobs database and pass a reference to grpc server and middleware
-	db := &JobsDB{jobs: map[string]jobList{}}
-	m := Middleware{db}
+	// Initialise admission control using the host's total resources
+	host, err := jobworker.HostTotals()
+	if err != nil {
+		log.Fatalf("failed to get host resources: %v", err)
+	}
+	admission := jobworker.NewAdmission(host, jobworker.ADMISSION_OVERCOMMIT_RATIO)
+	// Queue jobs once the maximum number of running jobs is reached, starting them with admission control
+	order := jobworker.QueueOrder(jobworker.QUEUE_ORDER)
+	queue, err := jobworker.NewQueue(jobworker.MAX_RUNNING_JOBS, jobworker.MAX_RUNNING_JOBS_PER_OWNER, order, admission.StartJob)
+	if err != nil {
+		log.Fatalf("failed to create job queue: %v", err)
+	}
+	// Use cgroup v1 or v2 depending on which hierarchy the host has mounted, falling back to process limits if the
+	// executing user can't create cgroups
+	con, err := jobworker.SelectController("/proc/self/mountinfo")
+	if err != nil {
+		log.Printf("cgroups not available, resource controls will be best-effort: %v", err)
+	}
+	log.Printf("using %T resource controller", con)
+	// Initialise jobs, schedules, workflows and arrays databases and pass a reference to grpc server and middleware
+	db := &JobsDB{jobs: map[string]jobList{}, names: map[string]jobList{}}
+	schedules := &SchedulesDB{schedules: map[string]scheduleList{}}
+	workflows := &WorkflowsDB{workflows: map[string]workflowList{}}
+	arrays := &ArraysDB{arrays: map[string]arrayList{}}
+	m := Middleware{db: db, schedules: schedules, workflows: workflows, arrays: arrays}
 	// Set up gRPC server
 	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)), grpc.UnaryInterceptor(m.Unary), grpc.StreamInterceptor(m.Stream))
-	pb.RegisterWorkerServer(s, newServer(db))
-	return s
+	requests := &RequestsDB{records: map[string]map[string]*startRecord{}}
+	server := newServer(db, schedules, workflows, arrays, requests, queue, admission, con)
+	pb.RegisterWorkerServer(s, server)
+	go server.reapEvery()
+	return &GrpcServer{Server: s, server: server}
 }
 
 // getOwner extracts the owner from a request's context metadata
@@ -91,29 +204,281 @@ func getOwner(ctx context.Context) (string, error) {
 	return owner[0], nil
 }
 
+// resourceExhausted converts the shortages of an admission error to a ResourceExhausted status with QuotaFailure details
+func resourceExhausted(exhausted *jobworker.ErrResourcesExhausted) error {
+	st := status.New(codes.ResourceExhausted, exhausted.Error())
+	failure := &errdetails.QuotaFailure{}
+	for _, s := range exhausted.Shortages {
+		failure.Violations = append(failure.Violations, &errdetails.QuotaFailure_Violation{
+			Subject:     s.Resource,
+			Description: s.String(),
+		})
+	}
+	if detailed, err := st.WithDetails(failure); err == nil {
+		return detailed.Err()
+	}
+	return st.Err()
+}
+
+// requestConflict returns an AlreadyExists error for a StartRequest whose request ID started a job or array with a
+// different request, with the job's or array's ID in the error details
+func requestConflict(requestID string, res *pb.StartResponse) error {
+	kind, id := "job", res.Id
+	if res.ArrayId != "" {
+		kind, id = "array", res.ArrayId
+	}
+	st := status.Newf(codes.AlreadyExists, "request ID %q already started %s %s with a different request", requestID, kind, id)
+	info := &errdetails.ResourceInfo{ResourceType: kind, ResourceName: id}
+	if detailed, err := st.WithDetails(info); err == nil {
+		return detailed.Err()
+	}
+	return st.Err()
+}
+
+// jobTimeout returns the timeout for an owner's job, limited to the owner's maximum. Jobs without a timeout are given
+// the maximum
+func jobTimeout(owner string, requested time.Duration) time.Duration {
+	limit, ok := jobworker.OWNER_MAX_JOB_TIMEOUT[owner]
+	if !ok {
+		limit = jobworker.MAX_JOB_TIMEOUT
+	}
+	if limit > 0 && (requested <= 0 || requested > limit) {
+		return limit
+	}
+	return requested
+}
+
+// jobOpts validates and converts a request's pb.JobOpts for an owner's job
+func jobOpts(owner string, opts *pb.JobOpts) (jobworker.JobOpts, error) {
+	if opts == nil {
+		return jobworker.JobOpts{}, status.Errorf(codes.InvalidArgument, "job options are required")
+	}
+	memLimit, err := jobworker.ParseCgroupByte(opts.MemLimit)
+	if err != nil {
+		return jobworker.JobOpts{}, status.Errorf(codes.InvalidArgument, "mem limit job option was not valid")
+	}
+	if opts.Timeout != nil {
+		if err = opts.Timeout.CheckValid(); err != nil || opts.Timeout.AsDuration() < 0 {
+			return jobworker.JobOpts{}, status.Errorf(codes.InvalidArgument, "timeout job option was not valid")
+		}
+	}
+	restartPolicy := jobworker.RestartPolicy(opts.RestartPolicy)
+	switch restartPolicy {
+	case "", jobworker.RestartNever, jobworker.RestartOnFailure, jobworker.RestartAlways:
+	default:
+		return jobworker.JobOpts{}, status.Errorf(codes.InvalidArgument, "restart policy job option was not valid")
+	}
+	if opts.MaxRetries < 0 {
+		return jobworker.JobOpts{}, status.Errorf(codes.InvalidArgument, "max retries job option was not valid")
+	}
+	if err = jobworker.ValidateCPUSet(opts.Cpuset); err != nil {
+		return jobworker.JobOpts{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return jobworker.JobOpts{
+		CPUWeight:     opts.CpuWeight,
+		IOWeight:      opts.IoWeight,
+		MemLimit:      memLimit,
+		CPUMax:        opts.CpuMax,
+		CPUSet:        opts.Cpuset,
+		Timeout:       jobTimeout(owner, opts.Timeout.AsDuration()),
+		RestartPolicy: restartPolicy,
+		MaxRetries:    opts.MaxRetries,
+		Priority:      opts.Priority,
+	}, nil
+}
+
+// startJob returns a jobworker.StartFunc that submits an owner's job to the queue and adds it to the DB. The job is
+// run if there's a free slot and the host has the resources available, otherwise it waits in the queue
+func (s *Server) startJob(owner string, metadata jobworker.Metadata) jobworker.StartFunc {
+	return func(opts jobworker.JobOpts, cmd string, args ...string) (*jobworker.Job, error) {
+		job := jobworker.NewJobWithController(s.con, opts, cmd, args...)
+		job.SetOwner(owner)
+		job.SetMetadata(metadata)
+		if err := s.db.ClaimName(owner, job); err != nil {
+			return nil, err
+		}
+		if err := s.submit(owner)(job); err != nil {
+			s.db.ReleaseName(owner, job)
+			return nil, err
+		}
+		s.db.Update(owner, job)
+		return job, nil
+	}
+}
+
+// submit returns a function that submits an owner's pending job to the queue
+func (s *Server) submit(owner string) func(*jobworker.Job) error {
+	return func(job *jobworker.Job) error {
+		return s.queue.Submit(owner, job)
+	}
+}
+
+// startAfter adds an owner's job to the DB as pending until the owner's jobs it depends on complete, then submits it
+// to the queue. Dependencies can be given by the job's ID, name or ID prefix
+func (s *Server) startAfter(owner string, metadata jobworker.Metadata, after []*pb.Dependency, opts jobworker.JobOpts, cmd string, args ...string) (*jobworker.Job, error) {
+	deps := []jobworker.Dependency{}
+	for _, dep := range after {
+		upstream, err := s.db.Resolve(owner, dep.Id)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if upstream == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "job depends on invalid job UUID %s", dep.Id)
+		}
+		deps = append(deps, jobworker.Dependency{Job: upstream, Condition: jobworker.DependencyCondition(dep.Condition)})
+	}
+	job := jobworker.NewJobWithController(s.con, opts, cmd, args...)
+	job.SetOwner(owner)
+	job.SetMetadata(metadata)
+	if err := s.db.ClaimName(owner, job); err != nil {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	if err := jobworker.StartAfter(job, deps, s.submit(owner)); err != nil {
+		s.db.ReleaseName(owner, job)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.db.Update(owner, job)
+	return job, nil
+}
+
 // Start runs a command as a job
 func (s *Server) Start(ctx context.Context, req *pb.StartRequest) (*pb.StartResponse, error) {
 	owner, err := getOwner(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
-	// Define job's command and options
-	memLimit, err := jobworker.ParseCgroupByte(req.Opts.MemLimit)
+	if req.RequestId == "" {
+		return s.start(owner, req)
+	}
+	if len(req.RequestId) > jobworker.MAX_REQUEST_ID_LENGTH {
+		return nil, status.Errorf(codes.InvalidArgument, "request ID must be at most %d characters", jobworker.MAX_REQUEST_ID_LENGTH)
+	}
+	// Return the job started by an earlier request with the same request ID, waiting for it to start if the request
+	// is still in flight. If it failed to start, this request tries again
+	for {
+		record, reserved := s.requests.Reserve(owner, req, time.Now())
+		if reserved {
+			break
+		}
+		select {
+		case <-record.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if record.res == nil {
+			continue
+		}
+		spec := proto.Clone(req).(*pb.StartRequest)
+		spec.RequestId = ""
+		if !proto.Equal(spec, record.req) {
+			return nil, requestConflict(req.RequestId, record.res)
+		}
+		return record.res, nil
+	}
+	res, err := s.start(owner, req)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "mem limit job option was not valid")
+		s.requests.Release(owner, req.RequestId)
+		return nil, err
 	}
-	opts := jobworker.JobOpts{req.Opts.CpuWeight, req.Opts.IoWeight, memLimit}
-	// Run the job
-	job, err := jobworker.Start(opts, req.Command, req.Args...)
+	s.requests.Complete(owner, req.RequestId, res, time.Now())
+	return res, nil
+}
+
+// start starts an owner's job, queueing it or waiting for the jobs it depends on
+func (s *Server) start(owner string, req *pb.StartRequest) (*pb.StartResponse, error) {
+	// Define job's command and options
+	opts, err := jobOpts(owner, req.Opts)
 	if err != nil {
+		return nil, err
+	}
+	metadata := jobworker.Metadata{Name: req.Name, Labels: req.Labels, Annotations: req.Annotations}
+	if err = metadata.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.Count < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "array count must not be negative")
+	}
+	if req.MaxParallel < 0 || req.MaxParallel > req.Count {
+		return nil, status.Errorf(codes.InvalidArgument, "array max parallel must be between 0 and the array's count")
+	}
+	if req.Count > 0 {
+		return s.startArray(owner, metadata, req, opts)
+	}
+	// Wait for the jobs it depends on, otherwise run the job if the host has the resources available
+	if len(req.After) > 0 {
+		job, err := s.startAfter(owner, metadata, req.After, opts, req.Command, req.Args...)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.StartResponse{Id: job.ID}, nil
+	}
+	job, err := s.startJob(owner, metadata)(opts, req.Command, req.Args...)
+	var exhausted *jobworker.ErrResourcesExhausted
+	if errors.As(err, &exhausted) {
+		fmt.Printf("rejected command: %v\n", err)
+		return nil, resourceExhausted(exhausted)
+	} else if errors.Is(err, ErrNameInUse) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	} else if err != nil {
 		fmt.Printf("failed to start command: %v\n", err)
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	s.db.Update(owner, job)
 	return &pb.StartResponse{Id: job.ID}, nil
 }
 
-// Stop kills a job's process and cleans up it's environment
+// startArray starts an array of the owner's jobs, adding each job to the DB so they can be managed like any other job
+func (s *Server) startArray(owner string, metadata jobworker.Metadata, req *pb.StartRequest, opts jobworker.JobOpts) (*pb.StartResponse, error) {
+	// Names are unique so can't be shared by the array's jobs
+	if metadata.Name != "" || len(req.After) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "an array can't be named or depend on other jobs")
+	}
+	array, err := jobworker.NewArray(s.con, owner, int(req.Count), int(req.MaxParallel), metadata, opts, s.submit(owner), req.Command, req.Args...)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	resp := &pb.StartResponse{ArrayId: array.ID, JobIds: []string{}}
+	for _, job := range array.Jobs() {
+		s.db.Update(owner, job)
+		resp.JobIds = append(resp.JobIds, job.ID)
+	}
+	s.arrays.Update(owner, array)
+	return resp, nil
+}
+
+// stopSteps validates and converts a stop request's escalation or grace period, defaulting to SIGTERM then SIGKILL
+// after STOP_GRACE_PERIOD. Signals in the escalation must be allowed
+func stopSteps(gracePeriod *durationpb.Duration, escalation []*pb.StopStep) ([]jobworker.StopStep, error) {
+	if len(escalation) == 0 {
+		grace := jobworker.STOP_GRACE_PERIOD
+		if gracePeriod != nil {
+			if err := gracePeriod.CheckValid(); err != nil || gracePeriod.AsDuration() < 0 {
+				return nil, status.Errorf(codes.InvalidArgument, "grace period was not valid")
+			}
+			grace = gracePeriod.AsDuration()
+		}
+		return jobworker.GracefulStop(grace), nil
+	}
+	steps := []jobworker.StopStep{}
+	for _, step := range escalation {
+		sig, err := jobworker.ParseSignal(step.Signal)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if !jobworker.SignalAllowed(sig) {
+			return nil, status.Errorf(codes.PermissionDenied, "signal %s is not permitted", sig)
+		}
+		if step.Wait != nil {
+			if err = step.Wait.CheckValid(); err != nil || step.Wait.AsDuration() < 0 {
+				return nil, status.Errorf(codes.InvalidArgument, "escalation wait was not valid")
+			}
+		}
+		steps = append(steps, jobworker.StopStep{Signal: sig, Wait: step.Wait.AsDuration()})
+	}
+	return steps, nil
+}
+
+// Stop begins stopping a job in the background and cleaning up it's environment, returning whether the job is still
+// stopping
 func (s *Server) Stop(ctx context.Context, req *pb.StopRequest) (*pb.StopResponse, error) {
 	owner, err := getOwner(ctx)
 	if err != nil {
@@ -125,15 +490,139 @@ func (s *Server) Stop(ctx context.Context, req *pb.StopRequest) (*pb.StopRespons
 		fmt.Printf("Job not found using id=%s\n", req.Id)
 		return nil, ErrNotFound
 	}
-	// Stop the job from executing
-	err = job.Stop(ctx)
+	steps, err := stopSteps(req.GracePeriod, req.Escalation)
 	if err != nil {
+		return nil, err
+	}
+	// Stop the job from executing, the caller can check the status to see once it has stopped
+	if err = job.StopAsync(steps); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	// TODO in production we would need to "clean" up these jobs by running some go routine to remove old terminated jobs
-	// Remove job from DB
-	// s.db.Remove(owner, job.ID)
-	return &pb.StopResponse{}, nil
+	return &pb.StopResponse{State: jobStates[job.Status().State]}, nil
+}
+
+// BulkStop begins stopping all of the owner's pending and running jobs with labels matching the selector
+func (s *Server) BulkStop(ctx context.Context, req *pb.BulkStopRequest) (*pb.BulkStopResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	selector, err := jobworker.ParseSelector(req.Selector)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// An empty selector would stop every job, which is more likely a mistake than intended
+	if len(selector) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "selector is required")
+	}
+	steps, err := stopSteps(req.GracePeriod, req.Escalation)
+	if err != nil {
+		return nil, err
+	}
+	filter := JobFilter{States: []jobworker.JobState{jobworker.JobPending, jobworker.JobRunning}, Selector: selector}
+	resp := &pb.BulkStopResponse{Ids: []string{}}
+	for _, job := range s.db.List(owner, filter) {
+		if err = job.StopAsync(steps); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		resp.Ids = append(resp.Ids, job.ID)
+	}
+	return resp, nil
+}
+
+// Delete removes a completed job, it's logs and cgroup
+func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	// Get Job from DB
+	job := s.db.Get(owner, req.Id)
+	if job == nil {
+		fmt.Printf("Job not found using id=%s\n", req.Id)
+		return nil, ErrNotFound
+	}
+	if err = job.Delete(); errors.Is(err, jobworker.ErrJobNotCompleted) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.db.Remove(owner, job.ID)
+	return &pb.DeleteResponse{}, nil
+}
+
+// reap deletes each owner's completed jobs, workflows and arrays that are outside the server's retention policy,
+// forgets schedule runs whose jobs have been deleted, and forgets start request IDs older than
+// jobworker.REQUEST_ID_WINDOW
+func (s *Server) reap(now time.Time) {
+	keep, ttl := s.retention.keep, s.retention.ttl
+	for _, owner := range s.db.Owners() {
+		for _, job := range jobworker.Expired(s.db.List(owner, JobFilter{}), keep, ttl, now) {
+			if err := job.Delete(); err != nil {
+				fmt.Printf("error deleting expired job %s: %v\n", job.ID, err)
+				continue
+			}
+			s.db.Remove(owner, job.ID)
+		}
+	}
+	for _, owner := range s.workflows.Owners() {
+		for _, workflow := range jobworker.ExpiredWorkflows(s.workflows.List(owner), keep, ttl, now) {
+			s.workflows.Remove(owner, workflow.ID)
+		}
+	}
+	for _, owner := range s.arrays.Owners() {
+		for _, array := range jobworker.ExpiredArrays(s.arrays.List(owner), keep, ttl, now) {
+			s.arrays.Remove(owner, array.ID)
+		}
+	}
+	for _, owner := range s.schedules.Owners() {
+		for _, schedule := range s.schedules.List(owner) {
+			schedule.ForgetRuns(func(id string) bool { return s.db.Get(owner, id) != nil })
+		}
+	}
+	s.requests.Expire(now)
+}
+
+// reapEvery reaps expired jobs at the retention policy's interval, until the server is stopped
+func (s *Server) reapEvery() {
+	ticker := time.NewTicker(s.retention.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.reap(now)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Signal sends an allowed signal to a job's main process, process group or cgroup
+func (s *Server) Signal(ctx context.Context, req *pb.SignalRequest) (*pb.SignalResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	// Get Job from DB
+	job := s.db.Get(owner, req.Id)
+	if job == nil {
+		fmt.Printf("Job not found using id=%s\n", req.Id)
+		return nil, ErrNotFound
+	}
+	sig, err := jobworker.ParseSignal(req.Signal)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !jobworker.SignalAllowed(sig) {
+		return nil, status.Errorf(codes.PermissionDenied, "signal %s is not permitted", sig)
+	}
+	err = job.Signal(sig, jobworker.SignalTarget(req.Target))
+	if errors.Is(err, jobworker.ErrJobNotRunning) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.SignalResponse{}, nil
 }
 
 // Status returns the status of a running job
@@ -148,14 +637,212 @@ func (s *Server) Status(ctx context.Context, req *pb.StatusRequest) (*pb.StatusR
 		fmt.Printf("Job not found using id=%s\n", req.Id)
 		return nil, ErrNotFound
 	}
-	// Convert job status to pb.JobStatus
-	status := job.Status()
-	return &pb.StatusResponse{JobStatus: &pb.JobStatus{
-		Id:       job.ID,
-		Pid:      status.PID,
-		Running:  status.Running,
-		ExitCode: int32(status.ExitCode),
-	}}, nil
+	return &pb.StatusResponse{JobStatus: jobStatus(job.Status())}, nil
+}
+
+// Wait blocks until a job has completed and returns it's final status, or the context's error if the client's deadline
+// passes or it cancels the request first
+func (s *Server) Wait(ctx context.Context, req *pb.WaitRequest) (*pb.WaitResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	// Get Job from DB
+	job := s.db.Get(owner, req.Id)
+	if job == nil {
+		fmt.Printf("Job not found using id=%s\n", req.Id)
+		return nil, ErrNotFound
+	}
+	final, err := job.Wait(ctx)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return &pb.WaitResponse{JobStatus: jobStatus(final)}, nil
+}
+
+// List returns a page of the owner's jobs matching the request's filters, oldest first
+func (s *Server) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	filter, err := jobFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.PageSize < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "page size was not valid")
+	}
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = jobworker.LIST_PAGE_SIZE
+	}
+	pageSize = min(pageSize, jobworker.LIST_MAX_PAGE_SIZE)
+	cursor, err := parsePageToken(req.PageToken)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "page token was not valid")
+	}
+	resp := &pb.ListResponse{Jobs: []*pb.JobStatus{}}
+	var last jobworker.JobStatus
+	for _, job := range s.db.List(owner, filter) {
+		st := job.Status()
+		if !cursor.before(st) {
+			continue
+		}
+		// Only return a token if there's another page
+		if len(resp.Jobs) == pageSize {
+			resp.NextPageToken = pageToken(last)
+			break
+		}
+		resp.Jobs = append(resp.Jobs, jobStatus(st))
+		last = st
+	}
+	return resp, nil
+}
+
+// jobFilter validates and converts a List request's filters
+func jobFilter(req *pb.ListRequest) (JobFilter, error) {
+	selector, err := jobworker.ParseSelector(req.Selector)
+	if err != nil {
+		return JobFilter{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	filter := JobFilter{Command: req.Command, Selector: selector}
+	for _, pbState := range req.States {
+		state, ok := pbJobStates[pbState]
+		if !ok {
+			return JobFilter{}, status.Errorf(codes.InvalidArgument, "state %s was not valid", pbState)
+		}
+		filter.States = append(filter.States, state)
+	}
+	if req.CreatedAfter != nil {
+		if err := req.CreatedAfter.CheckValid(); err != nil {
+			return JobFilter{}, status.Errorf(codes.InvalidArgument, "created after was not valid")
+		}
+		filter.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		if err := req.CreatedBefore.CheckValid(); err != nil {
+			return JobFilter{}, status.Errorf(codes.InvalidArgument, "created before was not valid")
+		}
+		filter.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+	return filter, nil
+}
+
+// listCursor is the position in an owner's jobs after which the next page starts, jobs are ordered by when they were
+// created then their ID. The zero value is before every job
+type listCursor struct {
+	created time.Time
+	id      string
+}
+
+// before returns true if the job comes after the cursor
+func (c listCursor) before(st jobworker.JobStatus) bool {
+	if c.id == "" {
+		return true
+	}
+	return st.Created.After(c.created) || (st.Created.Equal(c.created) && st.ID > c.id)
+}
+
+// pageToken encodes the cursor after the last job of a page as an opaque token
+func pageToken(last jobworker.JobStatus) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d/%s", last.Created.UnixNano(), last.ID)))
+}
+
+// parsePageToken decodes a token from pageToken, an empty token starts from the first job
+func parsePageToken(token string) (listCursor, error) {
+	if token == "" {
+		return listCursor{}, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return listCursor{}, err
+	}
+	nanos, id, ok := strings.Cut(string(b), "/")
+	if !ok || id == "" {
+		return listCursor{}, fmt.Errorf("page token %q not valid", token)
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return listCursor{}, err
+	}
+	return listCursor{created: time.Unix(0, n), id: id}, nil
+}
+
+// jobStates maps each jobworker.JobState to it's pb.JobState
+var jobStates = map[jobworker.JobState]pb.JobState{
+	jobworker.JobPending:  pb.JobState_JOB_STATE_PENDING,
+	jobworker.JobRunning:  pb.JobState_JOB_STATE_RUNNING,
+	jobworker.JobStopping: pb.JobState_JOB_STATE_STOPPING,
+	jobworker.JobExited:   pb.JobState_JOB_STATE_EXITED,
+	jobworker.JobStopped:  pb.JobState_JOB_STATE_STOPPED,
+	jobworker.JobFailed:   pb.JobState_JOB_STATE_FAILED,
+}
+
+// pbJobStates maps each pb.JobState to it's jobworker.JobState
+var pbJobStates = map[pb.JobState]jobworker.JobState{}
+
+func init() {
+	for state, pbState := range jobStates {
+		pbJobStates[pbState] = state
+	}
+}
+
+// pbJobOpts converts a job's effective jobworker.JobOpts to a pb.JobOpts
+func pbJobOpts(opts jobworker.JobOpts) *pb.JobOpts {
+	pbOpts := &pb.JobOpts{
+		CpuWeight:     opts.CPUWeight,
+		IoWeight:      opts.IOWeight,
+		MemLimit:      opts.MemLimit.String(),
+		CpuMax:        opts.CPUMax,
+		Cpuset:        opts.CPUSet,
+		RestartPolicy: string(opts.RestartPolicy),
+		MaxRetries:    opts.MaxRetries,
+		Priority:      opts.Priority,
+	}
+	if opts.Timeout > 0 {
+		pbOpts.Timeout = durationpb.New(opts.Timeout)
+	}
+	return pbOpts
+}
+
+// jobStatus converts a jobworker.JobStatus to a pb.JobStatus
+func jobStatus(status jobworker.JobStatus) *pb.JobStatus {
+	capabilities := map[string]string{}
+	for control, enforcement := range status.Capabilities {
+		capabilities[control] = string(enforcement)
+	}
+	pbStatus := &pb.JobStatus{
+		Id:            status.ID,
+		Name:          status.Name,
+		Pid:           status.PID,
+		Running:       status.Running,
+		ExitCode:      int32(status.ExitCode),
+		Capabilities:  capabilities,
+		ExitReason:    string(status.ExitReason),
+		Restarts:      status.Restarts,
+		LastExitCode:  status.LastExitCode,
+		Queued:        status.Queued,
+		QueuePosition: status.QueuePosition,
+		Pending:       status.Pending,
+		Command:       status.Command,
+		Args:          status.Args,
+		Owner:         status.Owner,
+		Opts:          pbJobOpts(status.Opts),
+		Created:       timestamppb.New(status.Created),
+		Duration:      durationpb.New(status.Duration),
+		State:         jobStates[status.State],
+		Retained:      status.Retained,
+		Labels:        status.Labels,
+		Annotations:   status.Annotations,
+	}
+	if !status.Started.IsZero() {
+		pbStatus.Started = timestamppb.New(status.Started)
+	}
+	if !status.Finished.IsZero() {
+		pbStatus.Finished = timestamppb.New(status.Finished)
+	}
+	return pbStatus
 }
 
 // Output pipes the STDOUT and STDERR of a job to a gRPC stream
@@ -192,3 +879,300 @@ func (s *Server) Output(req *pb.OutputRequest, stream pb.Worker_OutputServer) er
 	}
 	return nil
 }
+
+// Watch streams lifecycle events for the owner's jobs until the client cancels the stream, optionally only the
+// requested jobs or those with labels matching the selector. A client that falls too far behind is disconnected and can
+// resume from the last revision it received
+func (s *Server) Watch(req *pb.WatchRequest, stream pb.Worker_WatchServer) error {
+	ctx := stream.Context()
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	// A request without a revision watches new events, from the revision before resolving the jobs so events published
+	// in the meantime are still sent, and a disconnected client can resume from it even if none were sent
+	last := req.Revision
+	if last == 0 {
+		last = jobworker.Revision()
+	}
+	selector, err := jobworker.ParseSelector(req.Selector)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	// Jobs can be referred to by name or ID prefix, like the other RPCs
+	ids := []string{}
+	for _, ref := range req.Ids {
+		job, err := resolveJob(s.db, owner, ref)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, job.ID)
+	}
+	events, err := jobworker.Watch(ctx, last, func(event jobworker.Event) bool {
+		return event.Owner == owner && (len(ids) == 0 || slices.Contains(ids, event.JobID)) && selector.Matches(event.Status.Labels)
+	})
+	if err != nil {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	for event := range events {
+		err = stream.Send(&pb.Event{
+			Revision:  event.Revision,
+			Type:      string(event.Type),
+			JobId:     event.JobID,
+			Time:      timestamppb.New(event.Time),
+			JobStatus: jobStatus(event.Status),
+		})
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		last = event.Revision
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return status.Errorf(codes.ResourceExhausted, "watch fell behind, resume from revision %d", last)
+}
+
+// Leaks returns the cgroups and log files that could not be removed when stopping jobs, this is restricted to admins
+// by the authz middleware
+func (s *Server) Leaks(ctx context.Context, req *pb.LeaksRequest) (*pb.LeaksResponse, error) {
+	resp := &pb.LeaksResponse{Leaks: []*pb.Leak{}}
+	for _, leak := range jobworker.Leaks() {
+		resp.Leaks = append(resp.Leaks, &pb.Leak{
+			Kind:     string(leak.Kind),
+			JobId:    leak.JobID,
+			Resource: leak.Resource,
+			Error:    leak.Err,
+			Time:     timestamppb.New(leak.Time),
+		})
+	}
+	return resp, nil
+}
+
+// Schedule registers a job template that launches a job for the owner each time the schedule is due
+func (s *Server) Schedule(ctx context.Context, req *pb.ScheduleRequest) (*pb.ScheduleResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	opts, err := jobOpts(owner, req.Opts)
+	if err != nil {
+		return nil, err
+	}
+	policy := jobworker.ConcurrencyPolicy(req.ConcurrencyPolicy)
+	schedule, err := jobworker.NewSchedule(req.Spec, policy, s.startJob(owner, jobworker.Metadata{}), opts, req.Command, req.Args...)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.schedules.Update(owner, schedule)
+	return &pb.ScheduleResponse{Id: schedule.ID}, nil
+}
+
+// ListSchedules returns the status of all of the owner's schedules
+func (s *Server) ListSchedules(ctx context.Context, req *pb.ListSchedulesRequest) (*pb.ListSchedulesResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	resp := &pb.ListSchedulesResponse{Schedules: []*pb.ScheduleStatus{}}
+	for _, schedule := range s.schedules.List(owner) {
+		st := schedule.Status()
+		pbStatus := &pb.ScheduleStatus{
+			Id:                st.ID,
+			Spec:              st.Spec,
+			Command:           st.Command,
+			Args:              st.Args,
+			ConcurrencyPolicy: string(st.Policy),
+			Paused:            st.Paused,
+			Runs:              st.Runs,
+		}
+		if !st.Next.IsZero() {
+			pbStatus.Next = timestamppb.New(st.Next)
+		}
+		resp.Schedules = append(resp.Schedules, pbStatus)
+	}
+	return resp, nil
+}
+
+// PauseSchedule stops a schedule launching jobs until it's resumed
+func (s *Server) PauseSchedule(ctx context.Context, req *pb.PauseScheduleRequest) (*pb.PauseScheduleResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	schedule := s.schedules.Get(owner, req.Id)
+	if schedule == nil {
+		fmt.Printf("Schedule not found using id=%s\n", req.Id)
+		return nil, ErrScheduleNotFound
+	}
+	schedule.Pause()
+	return &pb.PauseScheduleResponse{}, nil
+}
+
+// ResumeSchedule continues launching jobs from a paused schedule
+func (s *Server) ResumeSchedule(ctx context.Context, req *pb.ResumeScheduleRequest) (*pb.ResumeScheduleResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	schedule := s.schedules.Get(owner, req.Id)
+	if schedule == nil {
+		fmt.Printf("Schedule not found using id=%s\n", req.Id)
+		return nil, ErrScheduleNotFound
+	}
+	schedule.Resume()
+	return &pb.ResumeScheduleResponse{}, nil
+}
+
+// DeleteSchedule stops and removes a schedule, jobs it already launched are not stopped
+func (s *Server) DeleteSchedule(ctx context.Context, req *pb.DeleteScheduleRequest) (*pb.DeleteScheduleResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	schedule := s.schedules.Get(owner, req.Id)
+	if schedule == nil {
+		fmt.Printf("Schedule not found using id=%s\n", req.Id)
+		return nil, ErrScheduleNotFound
+	}
+	schedule.Delete()
+	s.schedules.Remove(owner, req.Id)
+	return &pb.DeleteScheduleResponse{}, nil
+}
+
+// SubmitWorkflow starts a DAG of jobs for the owner, where each job is started once the jobs it depends on complete
+func (s *Server) SubmitWorkflow(ctx context.Context, req *pb.SubmitWorkflowRequest) (*pb.SubmitWorkflowResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	nodes := []jobworker.WorkflowNode{}
+	for _, node := range req.Nodes {
+		opts, err := jobOpts(owner, node.Opts)
+		if err != nil {
+			return nil, err
+		}
+		after := []jobworker.WorkflowDependency{}
+		for _, dep := range node.After {
+			after = append(after, jobworker.WorkflowDependency{Node: dep.Node, Condition: jobworker.DependencyCondition(dep.Condition)})
+		}
+		nodes = append(nodes, jobworker.WorkflowNode{
+			Name:    node.Name,
+			Command: node.Command,
+			Args:    node.Args,
+			Opts:    opts,
+			After:   after,
+		})
+	}
+	workflow, err := jobworker.NewWorkflow(s.con, owner, nodes, s.submit(owner))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// Add each node's job to the DB so they can be managed like any other job
+	resp := &pb.SubmitWorkflowResponse{Id: workflow.ID, Jobs: map[string]string{}}
+	for name, job := range workflow.Jobs() {
+		s.db.Update(owner, job)
+		resp.Jobs[name] = job.ID
+	}
+	s.workflows.Update(owner, workflow)
+	return resp, nil
+}
+
+// WorkflowStatus returns the status of every node in a workflow and it's overall state
+func (s *Server) WorkflowStatus(ctx context.Context, req *pb.WorkflowStatusRequest) (*pb.WorkflowStatusResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	workflow := s.workflows.Get(owner, req.Id)
+	if workflow == nil {
+		fmt.Printf("Workflow not found using id=%s\n", req.Id)
+		return nil, ErrWorkflowNotFound
+	}
+	st := workflow.Status()
+	resp := &pb.WorkflowStatusResponse{Id: st.ID, State: string(st.State), Nodes: []*pb.WorkflowNodeStatus{}}
+	for _, node := range st.Nodes {
+		resp.Nodes = append(resp.Nodes, &pb.WorkflowNodeStatus{Name: node.Name, JobStatus: jobStatus(node.JobStatus)})
+	}
+	return resp, nil
+}
+
+// ArrayStatus returns the status of every job in an array and it's overall state
+func (s *Server) ArrayStatus(ctx context.Context, req *pb.ArrayStatusRequest) (*pb.ArrayStatusResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	array := s.arrays.Get(owner, req.Id)
+	if array == nil {
+		fmt.Printf("Array not found using id=%s\n", req.Id)
+		return nil, ErrArrayNotFound
+	}
+	st := array.Status()
+	resp := &pb.ArrayStatusResponse{Id: st.ID, State: string(st.State), MaxParallel: int32(st.Parallel), Jobs: []*pb.JobStatus{}}
+	for _, job := range st.Jobs {
+		resp.Jobs = append(resp.Jobs, jobStatus(job))
+	}
+	return resp, nil
+}
+
+// ArrayOutput streams the logs of every job in an array, each line prefixed with the index of the job that wrote it
+func (s *Server) ArrayOutput(req *pb.ArrayOutputRequest, stream pb.Worker_ArrayOutputServer) error {
+	ctx := stream.Context()
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	array := s.arrays.Get(owner, req.Id)
+	if array == nil {
+		fmt.Printf("Array not found using id=%s\n", req.Id)
+		return ErrArrayNotFound
+	}
+	mode := jobworker.DontFollowLogs
+	if req.GetFollow() {
+		mode = jobworker.FollowLogs
+	}
+	reader := array.Output(mode)
+	defer reader.Close()
+	// Stop reading the logs if the client terminates the conn
+	go func() {
+		<-ctx.Done()
+		reader.Close()
+	}()
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		err = stream.Send(&pb.Data{Bytes: scanner.Bytes()})
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	return nil
+}
+
+// StopArray stops every job in an array that hasn't completed in the background, cancelling jobs waiting for a slot
+func (s *Server) StopArray(ctx context.Context, req *pb.StopArrayRequest) (*pb.StopArrayResponse, error) {
+	owner, err := getOwner(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	array := s.arrays.Get(owner, req.Id)
+	if array == nil {
+		fmt.Printf("Array not found using id=%s\n", req.Id)
+		return nil, ErrArrayNotFound
+	}
+	steps, err := stopSteps(req.GracePeriod, req.Escalation)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.StopArrayResponse{Ids: []string{}}
+	for _, job := range array.Jobs() {
+		if !job.Status().Finished.IsZero() {
+			continue
+		}
+		if err = job.StopAsync(steps); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		resp.Ids = append(resp.Ids, job.ID)
+	}
+	return resp, nil
+}
@@ -4,13 +4,23 @@ package rpc
 
 import (
 	"context"
+	"errors"
+	"io"
 	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/teleport-jobworker/pkg/jobworker"
 	pb "github.com/teleport-jobworker/pkg/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // TODO in production these tests would be separated out and potentially use table tests to improve the range of tests
@@ -18,7 +28,7 @@ import (
 func TestGRPCServer_Can_Start_Get_Status_And_Stop_Jobs(t *testing.T) {
 	// Run grpc server and shutdown after test
 	s := NewServer()
-	go startServer(s)
+	startServer(s)
 	defer s.GracefulStop()
 	// Create grpc client
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -30,21 +40,47 @@ func TestGRPCServer_Can_Start_Get_Status_And_Stop_Jobs(t *testing.T) {
 	defer conn.Close()
 	// Start a job with a long running process
 	var jobId string
-	if jobId, err = Start(ctx, client, "bash", []string{"-c", "while true; do echo hello; sleep 1; done"}, 100, 100, "100M"); err != nil {
+	if jobId, err = Start(ctx, client, "bash", []string{"-c", "while true; do echo hello; sleep 1; done"}, &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}); err != nil {
 		t.Errorf("expected start job to return non nil error: actual error %v", err)
 	}
+	// Assert a cpuset with CPUs the host doesn't have is rejected
+	if _, err = Start(ctx, client, "bash", []string{"-c", "exit 0"}, &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M", Cpuset: "0-2000000000"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected cpuset beyond the host's CPUs to be invalid, actual %v", err)
+	}
 	// Assert the status show it's running
 	var status *pb.JobStatus
 	if status, err = Status(ctx, client, jobId); err != nil {
 		t.Errorf("expected status to return non nil error: actual error %v", err)
 	}
-	if !status.Running {
+	if !status.Running || status.State != pb.JobState_JOB_STATE_RUNNING {
 		t.Error("expected job to be running and it isn't")
 	}
+	if status.Owner != "localhost" || status.Command != "bash" || status.Opts.GetMemLimit() != "104857600" || status.Started == nil {
+		t.Errorf("expected status to report the job's owner, command, options and start time, actual %v", status)
+	}
+	// Wait for the job to write it's first line, so it's in the logs before the job is stopped
+	follow, err := client.Output(ctx, &pb.OutputRequest{Id: jobId, Follow: true})
+	if err != nil {
+		t.Fatalf("expected to follow the job's logs: %v", err)
+	}
+	if _, err = follow.Recv(); err != nil {
+		t.Fatalf("expected the job to write to it's logs: %v", err)
+	}
 	// Stop the job and assert no errors and process isn't running
 	if err = Stop(ctx, client, jobId); err != nil {
 		t.Errorf("expected stop to return non nil error: actual error %v", err)
 	}
+	if stopped := waitForState(ctx, t, client, jobId, pb.JobState_JOB_STATE_STOPPED); stopped.Finished == nil || !stopped.Retained {
+		t.Errorf("expected status to report when the job stopped and that it's retained, actual %v", stopped)
+	}
+	// Assert the stopped job's logs can still be read
+	stream, err := client.Output(ctx, &pb.OutputRequest{Id: jobId})
+	if err != nil {
+		t.Fatalf("expected to read stopped job's logs: %v", err)
+	}
+	if data, err := stream.Recv(); err != nil || string(data.Bytes) != "hello" {
+		t.Errorf("expected stopped job's logs, actual %v, error %v", data, err)
+	}
 	_, err = os.FindProcess(int(status.Pid))
 	if err != nil {
 		t.Errorf("expected process not to be running")
@@ -57,7 +93,7 @@ func TestGRPCServer_Can_Start_Get_Status_And_Stop_Jobs(t *testing.T) {
 func TestGrpcServer_Authz(t *testing.T) {
 	// Run grpc server and shutdown after test
 	s := NewServer()
-	go startServer(s)
+	startServer(s)
 	defer s.GracefulStop()
 	// Create grpc client
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -69,7 +105,7 @@ func TestGrpcServer_Authz(t *testing.T) {
 	defer conn.Close()
 	// Start a job with a long running process
 	var jobId string
-	if jobId, err = Start(ctx, client, "bash", []string{"-c", "while true; do echo hello; sleep 1; done"}, 100, 100, "100M"); err != nil {
+	if jobId, err = Start(ctx, client, "bash", []string{"-c", "while true; do echo hello; sleep 1; done"}, &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}); err != nil {
 		t.Errorf("expected start job to return non nil error: actual error %v", err)
 	}
 
@@ -109,3 +145,841 @@ func TestGrpcServer_Authz(t *testing.T) {
 		t.Errorf("expected stop to not return an error %v", err)
 	}
 }
+
+// TestGrpcServer_Schedules ensures a schedule launches jobs owned by it's owner and can only be managed by it's owner
+func TestGrpcServer_Schedules(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc clients
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn2, client2, err := newClient2(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	// Schedule a job every second
+	scheduleId, err := Schedule(ctx, client, "@every 1s", "forbid", "bash", []string{"-c", "echo hello"}, &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"})
+	if err != nil {
+		t.Fatalf("expected schedule to not return an error: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	// Assert the schedule launched a job the owner can query
+	schedules, err := ListSchedules(ctx, client)
+	if err != nil {
+		t.Fatalf("expected list schedules to not return an error: %v", err)
+	}
+	if len(schedules) != 1 || len(schedules[0].Runs) != 1 {
+		t.Fatalf("expected 1 schedule with 1 run, actual %v", schedules)
+	}
+	if _, err = Status(ctx, client, schedules[0].Runs[0]); err != nil {
+		t.Errorf("expected status of scheduled run to not return an error: %v", err)
+	}
+	// Assert another client can't see or manage the schedule
+	if schedules, err = ListSchedules(ctx, client2); err != nil || len(schedules) != 0 {
+		t.Errorf("expected client2 to have no schedules, actual %v, error %v", schedules, err)
+	}
+	if err = PauseSchedule(ctx, client2, scheduleId); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected return code to be unauthenticated, actual: %v", err)
+	}
+	// Assert the owner can pause and delete the schedule
+	if err = PauseSchedule(ctx, client, scheduleId); err != nil {
+		t.Errorf("expected pause to not return an error: %v", err)
+	}
+	if err = DeleteSchedule(ctx, client, scheduleId); err != nil {
+		t.Errorf("expected delete to not return an error: %v", err)
+	}
+	if schedules, err = ListSchedules(ctx, client); err != nil || len(schedules) != 0 {
+		t.Errorf("expected schedule to be deleted, actual %v, error %v", schedules, err)
+	}
+}
+
+// TestGrpcServer_Queues_Jobs ensures jobs are queued once the owner's running job limit is reached and queued jobs can
+// be cancelled
+func TestGrpcServer_Queues_Jobs(t *testing.T) {
+	// Run grpc server limited to 1 running job per owner and shutdown after test
+	jobworker.MAX_RUNNING_JOBS_PER_OWNER = 1
+	defer func() { jobworker.MAX_RUNNING_JOBS_PER_OWNER = 0 }()
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Start 2 long running jobs
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	runningId, err := Start(ctx, client, "bash", []string{"-c", "while true; do sleep 1; done"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	queuedId, err := Start(ctx, client, "bash", []string{"-c", "while true; do sleep 1; done"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	// Assert the second job is queued behind the first
+	st, err := Status(ctx, client, queuedId)
+	if err != nil {
+		t.Fatalf("expected status to not return an error: %v", err)
+	}
+	if !st.Queued || st.QueuePosition != 1 || st.Running {
+		t.Errorf("expected second job to be queued at position 1, actual %v", st)
+	}
+	// Assert the queued job can be cancelled
+	if err = Stop(ctx, client, queuedId); err != nil {
+		t.Errorf("expected stop of queued job to not return an error: %v", err)
+	}
+	if st, err = Status(ctx, client, queuedId); err != nil || st.Queued || st.ExitReason != "stopped" {
+		t.Errorf("expected queued job to be cancelled, actual %v, error %v", st, err)
+	}
+	if err = Stop(ctx, client, runningId); err != nil {
+		t.Errorf("expected stop to not return an error: %v", err)
+	}
+}
+
+// TestGrpcServer_Dependencies_And_Workflows ensures jobs wait for the jobs they depend on, failures propagate as skipped
+// and a workflow reports the state of all of it's nodes
+func TestGrpcServer_Dependencies_And_Workflows(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	// Assert a job depending on a failed job is skipped
+	failedId, err := Start(ctx, client, "bash", []string{"-c", "sleep 1; exit 1"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	dependentId, err := Start(ctx, client, "bash", []string{"-c", "echo hello"}, opts, &pb.Dependency{Id: failedId})
+	if err != nil {
+		t.Fatalf("expected start job with dependency to not return an error: %v", err)
+	}
+	if st, err := Status(ctx, client, dependentId); err != nil || !st.Pending {
+		t.Errorf("expected dependent job to be pending, actual %v, error %v", st, err)
+	}
+	time.Sleep(2 * time.Second)
+	if st, err := Status(ctx, client, dependentId); err != nil || st.ExitReason != "skipped" {
+		t.Errorf("expected dependent job to be skipped, actual %v, error %v", st, err)
+	}
+	// Assert a job can't depend on an unknown job
+	if _, err = Start(ctx, client, "bash", []string{"-c", "echo hello"}, opts, &pb.Dependency{Id: "unknown"}); err == nil {
+		t.Error("expected start job depending on unknown job to return an error")
+	}
+	// Assert a workflow runs each node after it's dependencies
+	id, jobs, err := SubmitWorkflow(ctx, client, []*pb.WorkflowNode{
+		{Name: "extract", Command: "bash", Args: []string{"-c", "exit 0"}, Opts: opts},
+		{Name: "load", Command: "bash", Args: []string{"-c", "exit 0"}, Opts: opts, After: []*pb.WorkflowDependency{{Node: "extract"}}},
+	})
+	if err != nil {
+		t.Fatalf("expected submit workflow to not return an error: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Errorf("expected a job for each node, actual %v", jobs)
+	}
+	time.Sleep(time.Second)
+	st, err := WorkflowStatus(ctx, client, id)
+	if err != nil {
+		t.Fatalf("expected workflow status to not return an error: %v", err)
+	}
+	if st.State != "succeeded" || len(st.Nodes) != 2 {
+		t.Errorf("expected workflow to succeed, actual %v", st)
+	}
+	// Assert a workflow with a cycle is rejected
+	_, _, err = SubmitWorkflow(ctx, client, []*pb.WorkflowNode{
+		{Name: "a", Command: "bash", Opts: opts, After: []*pb.WorkflowDependency{{Node: "b"}}},
+		{Name: "b", Command: "bash", Opts: opts, After: []*pb.WorkflowDependency{{Node: "a"}}},
+	})
+	if err == nil {
+		t.Error("expected workflow with a cycle to be rejected")
+	}
+}
+
+// TestGrpcServer_Signals_Jobs ensures allowed signals are delivered to a job and other signals are denied
+func TestGrpcServer_Signals_Jobs(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Start a job that reloads on SIGHUP rather than exiting
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	jobId, err := Start(ctx, client, "bash", []string{"-c", "trap 'echo reloaded' HUP; while true; do sleep 0.1; done"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	defer Stop(ctx, client, jobId)
+	time.Sleep(100 * time.Millisecond)
+	if err = Signal(ctx, client, jobId, "HUP", "process"); err != nil {
+		t.Errorf("expected signal to not return an error: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if st, err := Status(ctx, client, jobId); err != nil || !st.Running {
+		t.Errorf("expected job to still be running after SIGHUP, actual %v, error %v", st, err)
+	}
+	// Assert signals outside the allowlist and unknown signals are rejected
+	if err = Signal(ctx, client, jobId, "STOP", ""); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected SIGSTOP to be denied, actual %v", err)
+	}
+	if err = Signal(ctx, client, jobId, "SIGFOO", ""); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected unknown signal to be invalid, actual %v", err)
+	}
+	// Assert SIGTERM terminates the job
+	if err = Signal(ctx, client, jobId, "TERM", "group"); err != nil {
+		t.Errorf("expected signal to not return an error: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if st, err := Status(ctx, client, jobId); err != nil || st.Running {
+		t.Errorf("expected job to be terminated by SIGTERM, actual %v, error %v", st, err)
+	}
+}
+
+// waitForState polls a job's status until it reaches the state, failing the test if it doesn't within 5 seconds
+func waitForState(ctx context.Context, t *testing.T, client pb.WorkerClient, id string, state pb.JobState) *pb.JobStatus {
+	t.Helper()
+	var st *pb.JobStatus
+	var err error
+	for i := 0; i < 50; i++ {
+		if st, err = Status(ctx, client, id); err == nil && st.State == state {
+			return st
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("expected job to be %s, actual %v, error %v", state, st, err)
+	return nil
+}
+
+// TestGrpcServer_Stops_Jobs_In_The_Background ensures stop returns while the job is stopping and escalates the signals
+func TestGrpcServer_Stops_Jobs_In_The_Background(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Start a job that ignores SIGINT and SIGTERM
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	jobId, err := Start(ctx, client, "bash", []string{"-c", "trap '' INT TERM; while true; do sleep 0.1; done"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	// Assert signals outside the allowlist can't be used to stop the job
+	req := &pb.StopRequest{Id: jobId, Escalation: []*pb.StopStep{{Signal: "STOP"}}}
+	if _, err = StopWith(ctx, client, req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected SIGSTOP to be denied, actual %v", err)
+	}
+	// Assert stop returns while the job is stopping, then escalates to SIGKILL
+	req = &pb.StopRequest{Id: jobId, Escalation: []*pb.StopStep{
+		{Signal: "INT", Wait: durationpb.New(500 * time.Millisecond)},
+		{Signal: "TERM", Wait: durationpb.New(500 * time.Millisecond)},
+		{Signal: "KILL"},
+	}}
+	state, err := StopWith(ctx, client, req)
+	if err != nil || state != pb.JobState_JOB_STATE_STOPPING {
+		t.Errorf("expected job to be stopping, actual %s, error %v", state, err)
+	}
+	waitForState(ctx, t, client, jobId, pb.JobState_JOB_STATE_STOPPED)
+}
+
+// TestGrpcServer_Waits_For_Jobs ensures wait blocks until a job completes and respects the client's deadline
+func TestGrpcServer_Waits_For_Jobs(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	jobId, err := Start(ctx, client, "bash", []string{"-c", "sleep 0.5; exit 3"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	// Assert the client's deadline is respected
+	short, shortCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer shortCancel()
+	if _, err = Wait(short, client, jobId); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expected wait to exceed it's deadline, actual %v", err)
+	}
+	// Assert wait returns the job's final status
+	final, err := Wait(ctx, client, jobId)
+	if err != nil || final.State != pb.JobState_JOB_STATE_FAILED || final.ExitCode != 3 || final.Finished == nil {
+		t.Errorf("expected wait to return the job's final status, actual %v, error %v", final, err)
+	}
+}
+
+// TestGrpcServer_Watches_Job_Events ensures a client receives it's job's lifecycle events and can resume from a revision
+func TestGrpcServer_Watches_Job_Events(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	revision := jobworker.Revision()
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	jobId, err := Start(ctx, client, "bash", []string{"-c", "exit 0"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	// watch collects the job's events until it exits
+	watch := func(from uint64) []*pb.Event {
+		watchCtx, watchCancel := context.WithCancel(ctx)
+		defer watchCancel()
+		events := []*pb.Event{}
+		Watch(watchCtx, client, &pb.WatchRequest{Revision: from, Ids: []string{jobId}}, func(event *pb.Event) {
+			events = append(events, event)
+			if event.Type == string(jobworker.EventExited) {
+				watchCancel()
+			}
+		})
+		return events
+	}
+	events := watch(revision)
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	if expected := []string{"created", "started", "exited"}; !slices.Equal(types, expected) {
+		t.Fatalf("expected events %v, actual %v", expected, types)
+	}
+	if exited := events[2]; exited.JobId != jobId || exited.JobStatus.State != pb.JobState_JOB_STATE_EXITED {
+		t.Errorf("expected exited event to report the job's status, actual %v", exited)
+	}
+	// Assert resuming from the first event skips it
+	if resumed := watch(events[0].Revision); len(resumed) != 2 || resumed[0].Revision != events[1].Revision {
+		t.Errorf("expected watch to resume after revision %d, actual %v", events[0].Revision, resumed)
+	}
+	// Assert revisions that don't exist are rejected
+	stream, err := client.Watch(ctx, &pb.WatchRequest{Revision: jobworker.Revision() + 100})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.OutOfRange {
+		t.Errorf("expected unavailable revision to be out of range, actual %v", err)
+	}
+}
+
+// TestGrpcServer_Watch_Without_Revision_Sends_New_Events ensures a watch without a revision only sends the events
+// published after it was requested, rather than the event history
+func TestGrpcServer_Watch_Without_Revision_Sends_New_Events(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Publish an earlier job's events before watching
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	earlier, err := Start(ctx, client, "bash", []string{"-c", "exit 0"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	waitForState(ctx, t, client, earlier, pb.JobState_JOB_STATE_EXITED)
+	stream, err := client.Watch(ctx, &pb.WatchRequest{})
+	if err != nil {
+		t.Fatalf("expected to watch events: %v", err)
+	}
+	// Receive the first event once the stream is established, so the next job is started after the watch
+	received := make(chan *pb.Event, 1)
+	go func() {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Errorf("expected to receive an event: %v", err)
+		}
+		received <- event
+	}()
+	time.Sleep(100 * time.Millisecond)
+	jobId, err := Start(ctx, client, "bash", []string{"-c", "exit 0"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	if event := <-received; event.GetJobId() != jobId || event.GetType() != string(jobworker.EventCreated) {
+		t.Errorf("expected created event for job %s, actual %v", jobId, event)
+	}
+}
+
+// TestGrpcServer_Deletes_And_Reaps_Jobs ensures completed jobs can be deleted and are reaped once outside the retention
+// policy
+func TestGrpcServer_Deletes_And_Reaps_Jobs(t *testing.T) {
+	interval, keep := jobworker.REAP_INTERVAL, jobworker.RETAIN_JOBS_PER_OWNER
+	jobworker.REAP_INTERVAL, jobworker.RETAIN_JOBS_PER_OWNER = 100*time.Millisecond, 1
+	defer func() { jobworker.REAP_INTERVAL, jobworker.RETAIN_JOBS_PER_OWNER = interval, keep }()
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	// Assert running jobs can't be deleted
+	running, err := Start(ctx, client, "bash", []string{"-c", "sleep 30"}, opts)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	defer Stop(ctx, client, running)
+	if err = Delete(ctx, client, running); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected running job to not be deleted, actual %v", err)
+	}
+	// Assert all but the most recently completed job are reaped
+	first, _ := Start(ctx, client, "bash", []string{"-c", "exit 0"}, opts)
+	Wait(ctx, client, first)
+	second, _ := Start(ctx, client, "bash", []string{"-c", "exit 0"}, opts)
+	Wait(ctx, client, second)
+	time.Sleep(300 * time.Millisecond)
+	if _, err = Status(ctx, client, first); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected expired job to be reaped, actual %v", err)
+	}
+	// Assert the retained job can be deleted
+	if err = Delete(ctx, client, second); err != nil {
+		t.Errorf("expected completed job to be deleted, error %v", err)
+	}
+	if _, err = Status(ctx, client, second); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected deleted job to be removed, actual %v", err)
+	}
+}
+
+// TestGrpcServer_Lists_Jobs ensures a client can list it's jobs with filters and pages
+func TestGrpcServer_Lists_Jobs(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	ids := []string{}
+	for _, script := range []string{"exit 0", "exit 1", "sleep 30"} {
+		id, err := Start(ctx, client, "bash", []string{"-c", script}, opts)
+		if err != nil {
+			t.Fatalf("expected start job to not return an error: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	defer Stop(ctx, client, ids[2])
+	Wait(ctx, client, ids[0])
+	Wait(ctx, client, ids[1])
+	// listIds lists the jobs matching the request and returns their IDs and the next page token
+	listIds := func(req *pb.ListRequest) ([]string, string) {
+		t.Helper()
+		jobs, next, err := List(ctx, client, req)
+		if err != nil {
+			t.Fatalf("expected list to not return an error: %v", err)
+		}
+		listed := []string{}
+		for _, job := range jobs {
+			listed = append(listed, job.Id)
+		}
+		return listed, next
+	}
+	tests := map[string]struct {
+		req      *pb.ListRequest
+		expected []string
+	}{
+		"all":     {req: &pb.ListRequest{}, expected: ids},
+		"state":   {req: &pb.ListRequest{States: []pb.JobState{pb.JobState_JOB_STATE_FAILED, pb.JobState_JOB_STATE_RUNNING}}, expected: ids[1:]},
+		"command": {req: &pb.ListRequest{Command: "sleep"}, expected: ids[2:]},
+		"created": {req: &pb.ListRequest{CreatedAfter: timestamppb.New(time.Now())}, expected: []string{}},
+	}
+	for name, tt := range tests {
+		if listed, _ := listIds(tt.req); !slices.Equal(listed, tt.expected) {
+			t.Errorf("%s: expected jobs %v, actual %v", name, tt.expected, listed)
+		}
+	}
+	// Assert the jobs can be paged through
+	first, next := listIds(&pb.ListRequest{PageSize: 2})
+	if !slices.Equal(first, ids[:2]) || next == "" {
+		t.Errorf("expected first page of jobs %v with a token, actual %v %q", ids[:2], first, next)
+	}
+	if second, last := listIds(&pb.ListRequest{PageSize: 2, PageToken: next}); !slices.Equal(second, ids[2:]) || last != "" {
+		t.Errorf("expected last page of jobs %v without a token, actual %v %q", ids[2:], second, last)
+	}
+	if _, _, err = List(ctx, client, &pb.ListRequest{PageToken: "not-a-token"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected invalid page token to be rejected, actual %v", err)
+	}
+}
+
+func TestGrpcServer_Selects_Jobs_By_Labels(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	ids := []string{}
+	for _, env := range []string{"prod", "dev", "dev"} {
+		req := &pb.StartRequest{
+			Command:     "sleep",
+			Args:        []string{"30"},
+			Opts:        opts,
+			Labels:      map[string]string{"team": "infra", "env": env},
+			Annotations: map[string]string{"description": "sleeps in " + env},
+		}
+		id, err := StartWith(ctx, client, req)
+		if err != nil {
+			t.Fatalf("expected start job to not return an error: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	defer Stop(ctx, client, ids[0])
+	// Assert the status includes the labels and annotations
+	jobStatus, err := Status(ctx, client, ids[0])
+	if err != nil {
+		t.Fatalf("expected status to not return an error: %v", err)
+	}
+	if jobStatus.Labels["env"] != "prod" || jobStatus.Annotations["description"] != "sleeps in prod" {
+		t.Errorf("expected status to include labels and annotations, actual %v %v", jobStatus.Labels, jobStatus.Annotations)
+	}
+	// Assert the jobs can be listed by selector
+	jobs, _, err := List(ctx, client, &pb.ListRequest{Selector: "team=infra,env!=prod"})
+	if err != nil {
+		t.Fatalf("expected list to not return an error: %v", err)
+	}
+	listed := []string{}
+	for _, job := range jobs {
+		listed = append(listed, job.Id)
+	}
+	if !slices.Equal(listed, ids[1:]) {
+		t.Errorf("expected jobs %v, actual %v", ids[1:], listed)
+	}
+	// Assert only the selected jobs are stopped
+	if _, err = BulkStop(ctx, client, &pb.BulkStopRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected bulk stop without a selector to be rejected, actual %v", err)
+	}
+	stopped, err := BulkStop(ctx, client, &pb.BulkStopRequest{Selector: "env=dev"})
+	if err != nil {
+		t.Fatalf("expected bulk stop to not return an error: %v", err)
+	}
+	slices.Sort(stopped)
+	expected := slices.Clone(ids[1:])
+	slices.Sort(expected)
+	if !slices.Equal(stopped, expected) {
+		t.Errorf("expected jobs %v to be stopped, actual %v", expected, stopped)
+	}
+	waitForState(ctx, t, client, ids[1], pb.JobState_JOB_STATE_STOPPED)
+	if jobStatus, err = Status(ctx, client, ids[0]); err != nil || jobStatus.State != pb.JobState_JOB_STATE_RUNNING {
+		t.Errorf("expected unselected job to still be running, actual %v %v", jobStatus.GetState(), err)
+	}
+	// Assert invalid labels and selectors are rejected
+	req := &pb.StartRequest{Command: "sleep", Args: []string{"30"}, Opts: opts, Labels: map[string]string{"not valid": "x"}}
+	if _, err = StartWith(ctx, client, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected invalid label to be rejected, actual %v", err)
+	}
+	if _, _, err = List(ctx, client, &pb.ListRequest{Selector: "env=not valid"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected invalid selector to be rejected, actual %v", err)
+	}
+}
+
+func TestGrpcServer_Starts_Jobs_Once_Per_Request_ID(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	req := &pb.StartRequest{RequestId: "retry-me", Command: "sleep", Args: []string{"30"}, Opts: opts}
+	// Assert concurrent retries of the request start a single job
+	ids := make([]string, 5)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i], _ = StartWith(ctx, client, req)
+		}()
+	}
+	wg.Wait()
+	defer Stop(ctx, client, ids[0])
+	for _, id := range ids {
+		if id == "" || id != ids[0] {
+			t.Fatalf("expected every retry to return the same job, actual %v", ids)
+		}
+	}
+	jobs, _, err := List(ctx, client, &pb.ListRequest{})
+	if err != nil || len(jobs) != 1 {
+		t.Errorf("expected a single job to be started, actual %d %v", len(jobs), err)
+	}
+	// Assert the request ID can't be reused for a different request
+	conflict := &pb.StartRequest{RequestId: "retry-me", Command: "sleep", Args: []string{"60"}, Opts: opts}
+	_, err = StartWith(ctx, client, conflict)
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected a different request with the same request ID to be rejected, actual %v", err)
+	}
+	details := status.Convert(err).Details()
+	if len(details) != 1 {
+		t.Fatalf("expected the error to have details, actual %v", details)
+	}
+	if info, ok := details[0].(*errdetails.ResourceInfo); !ok || info.ResourceName != ids[0] {
+		t.Errorf("expected the conflicting job %s in the error details, actual %v", ids[0], details)
+	}
+	// Assert a request ID that is too long is rejected
+	req = &pb.StartRequest{RequestId: strings.Repeat("a", 129), Command: "sleep", Args: []string{"30"}, Opts: opts}
+	if _, err = StartWith(ctx, client, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected a long request ID to be rejected, actual %v", err)
+	}
+}
+
+func TestGrpcServer_Refers_To_Jobs_By_Name_Or_ID_Prefix(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	req := &pb.StartRequest{Name: "nightly", Command: "sleep", Args: []string{"30"}, Opts: opts}
+	first, err := StartWith(ctx, client, req)
+	if err != nil {
+		t.Fatalf("expected start job to not return an error: %v", err)
+	}
+	// Assert the job can be referred to by it's name or a prefix of it's ID
+	for _, ref := range []string{"nightly", first[:8]} {
+		jobStatus, err := Status(ctx, client, ref)
+		if err != nil || jobStatus.Id != first || jobStatus.Name != "nightly" {
+			t.Errorf("expected %q to refer to job %s, actual %v %v", ref, first, jobStatus, err)
+		}
+	}
+	if err = Logs(ctx, client, "nightly", false); err != io.EOF {
+		t.Errorf("expected logs by name to not return an error: %v", err)
+	}
+	// Assert the name can't be reused until the job has completed
+	if _, err = StartWith(ctx, client, req); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("expected a job with the same name to be rejected, actual %v", err)
+	}
+	// Assert jobs can be watched by name, and unknown jobs are rejected
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	watched, err := client.Watch(watchCtx, &pb.WatchRequest{Ids: []string{"nightly"}, Revision: jobworker.Revision()})
+	if err != nil {
+		t.Fatalf("expected watch by name to not return an error: %v", err)
+	}
+	unknown, err := client.Watch(ctx, &pb.WatchRequest{Ids: []string{"weekly"}})
+	if err == nil {
+		_, err = unknown.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected watching an unknown job to be rejected, actual %v", err)
+	}
+	if err = Stop(ctx, client, "nightly"); err != nil {
+		t.Fatalf("expected stop by name to not return an error: %v", err)
+	}
+	if event, err := watched.Recv(); err != nil || event.JobId != first || event.Type != string(jobworker.EventStopped) {
+		t.Errorf("expected the job watched by name to be stopped, actual %v %v", event, err)
+	}
+	stopWatching()
+	waitForState(ctx, t, client, first, pb.JobState_JOB_STATE_STOPPED)
+	second, err := StartWith(ctx, client, req)
+	if err != nil {
+		t.Fatalf("expected the name to be reused once the job completed: %v", err)
+	}
+	defer Stop(ctx, client, second)
+	if jobStatus, err := Status(ctx, client, "nightly"); err != nil || jobStatus.Id != second {
+		t.Errorf("expected the name to refer to the most recent job %s, actual %v %v", second, jobStatus, err)
+	}
+	// Assert invalid names are rejected
+	req = &pb.StartRequest{Name: "not valid", Command: "sleep", Args: []string{"30"}, Opts: opts}
+	if _, err = StartWith(ctx, client, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an invalid name to be rejected, actual %v", err)
+	}
+}
+
+func TestJobsDB_Resolves_Jobs(t *testing.T) {
+	db := &JobsDB{jobs: map[string]jobList{}, names: map[string]jobList{}}
+	for _, id := range []string{"abc123", "abd456", "nightly0"} {
+		db.Update("alice", jobworker.NewJob(id, exec.Command("sleep", "30"), nil))
+	}
+	named := jobworker.NewJob("ffe789", exec.Command("sleep", "30"), nil)
+	named.SetMetadata(jobworker.Metadata{Name: "nightly"})
+	if err := db.ClaimName("alice", named); err != nil {
+		t.Fatalf("expected name to be claimed: %v", err)
+	}
+	tests := map[string]struct {
+		owner     string
+		ref       string
+		expected  string
+		ambiguous bool
+	}{
+		"id":              {owner: "alice", ref: "abc123", expected: "abc123"},
+		"prefix":          {owner: "alice", ref: "abd", expected: "abd456"},
+		"ambiguous":       {owner: "alice", ref: "ab", ambiguous: true},
+		"name not in db":  {owner: "alice", ref: "nightly", expected: "nightly0"},
+		"not found":       {owner: "alice", ref: "xyz"},
+		"empty":           {owner: "alice", ref: ""},
+		"different owner": {owner: "bob", ref: "abc123"},
+	}
+	for name, tt := range tests {
+		job, err := db.Resolve(tt.owner, tt.ref)
+		if errors.Is(err, ErrAmbiguousID) != tt.ambiguous {
+			t.Errorf("%s: expected ambiguous to be %t, actual %v", name, tt.ambiguous, err)
+		}
+		id := ""
+		if job != nil {
+			id = job.ID
+		}
+		if id != tt.expected {
+			t.Errorf("%s: expected job %q, actual %q", name, tt.expected, id)
+		}
+	}
+	// Assert the name refers to the job once it's in the DB
+	db.Update("alice", named)
+	if job, _ := db.Resolve("alice", "nightly"); job != named {
+		t.Errorf("expected the name to refer to job %s, actual %v", named.ID, job)
+	}
+}
+
+func TestGrpcServer_Starts_Job_Arrays(t *testing.T) {
+	// Run grpc server and shutdown after test
+	s := NewServer()
+	startServer(s)
+	defer s.GracefulStop()
+	// Create grpc client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, client, err := newClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	opts := &pb.JobOpts{CpuWeight: 100, IoWeight: 100, MemLimit: "100M"}
+	req := &pb.StartRequest{RequestId: "sweep", Command: "bash", Args: []string{"-c", "echo $JOB_ARRAY_INDEX/$JOB_ARRAY_SIZE"}, Opts: opts, Count: 3}
+	arrayID, ids, err := StartArray(ctx, client, req)
+	if err != nil || arrayID == "" || len(ids) != 3 {
+		t.Fatalf("expected an array of 3 jobs to start, actual %q %v %v", arrayID, ids, err)
+	}
+	// Assert a retried request returns the same array
+	if retried, _, err := StartArray(ctx, client, req); err != nil || retried != arrayID {
+		t.Errorf("expected retried request to return array %s, actual %s %v", arrayID, retried, err)
+	}
+	for _, id := range ids {
+		waitForState(ctx, t, client, id, pb.JobState_JOB_STATE_EXITED)
+	}
+	arrayStatus, err := ArrayStatus(ctx, client, arrayID)
+	if err != nil || arrayStatus.State != "succeeded" || len(arrayStatus.Jobs) != 3 {
+		t.Errorf("expected the array to succeed, actual %v %v", arrayStatus, err)
+	}
+	// Assert the logs are prefixed with each job's index
+	stream, err := client.ArrayOutput(ctx, &pb.ArrayOutputRequest{Id: arrayID})
+	if err != nil {
+		t.Fatalf("expected array logs to not return an error: %v", err)
+	}
+	logs := []string{}
+	for {
+		data, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		logs = append(logs, string(data.Bytes))
+	}
+	if expected := []string{"[0] 0/3", "[1] 1/3", "[2] 2/3"}; !slices.Equal(logs, expected) {
+		t.Errorf("expected array logs %v, actual %v", expected, logs)
+	}
+	// Assert stopping the array cancels the jobs waiting for a slot
+	req = &pb.StartRequest{Command: "sleep", Args: []string{"30"}, Opts: opts, Count: 3, MaxParallel: 1}
+	arrayID, ids, err = StartArray(ctx, client, req)
+	if err != nil {
+		t.Fatalf("expected array to start: %v", err)
+	}
+	waitForState(ctx, t, client, ids[0], pb.JobState_JOB_STATE_RUNNING)
+	if jobStatus, err := Status(ctx, client, ids[1]); err != nil || !jobStatus.Pending {
+		t.Errorf("expected job waiting for a slot to be pending, actual %v %v", jobStatus, err)
+	}
+	stopped, err := StopArray(ctx, client, &pb.StopArrayRequest{Id: arrayID})
+	if err != nil || len(stopped) != 3 {
+		t.Errorf("expected every job in the array to be stopped, actual %v %v", stopped, err)
+	}
+	for _, id := range ids {
+		waitForState(ctx, t, client, id, pb.JobState_JOB_STATE_STOPPED)
+	}
+	// Assert arrays can't be named, can't be too large or negative, and can't run more jobs at once than they have
+	for _, req := range []*pb.StartRequest{
+		{Name: "sweep", Command: "sleep", Args: []string{"30"}, Opts: opts, Count: 2},
+		{Command: "sleep", Args: []string{"30"}, Opts: opts, Count: int32(jobworker.MAX_ARRAY_SIZE + 1)},
+		{Command: "sleep", Args: []string{"30"}, Opts: opts, Count: -1},
+		{Command: "sleep", Args: []string{"30"}, Opts: opts, Count: 2, MaxParallel: 3},
+	} {
+		if _, _, err = StartArray(ctx, client, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected invalid array to be rejected, actual %v", err)
+		}
+	}
+}